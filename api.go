@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type doorResponse struct {
+	Name           string    `json:"name"`
+	State          string    `json:"state"`
+	LastChange     time.Time `json:"last_change"`
+	OpenForSeconds int64     `json:"open_for_seconds,omitempty"`
+	Muted          bool      `json:"muted"`
+}
+
+type notifyRequest struct {
+	Message  string `json:"message"`
+	Severity string `json:"severity"` // "info", "warning" or "critical"; default "info"
+}
+
+type muteRequest struct {
+	Door     string `json:"door"`
+	Duration string `json:"duration"` // e.g. "2h"; empty mutes until the door closes
+}
+
+// requireAPIKey wraps h so that requests must carry the key in an X-API-Key
+// header. An empty key disables the check.
+func requireAPIKey(key string, h http.Handler) http.Handler {
+	if key == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-API-Key")), []byte(key)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// apiHandler serves the JSON API. Routes that change what gets sent, muting
+// doors or sending messages, are only served when apiKey is set.
+func (a *App) apiHandler(apiKey string) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/doors", requireAPIKey(apiKey, http.HandlerFunc(a.doorsHandler)))
+	mux.Handle("/doors/", requireAPIKey(apiKey, http.HandlerFunc(a.doorHandler)))
+	if apiKey != "" {
+		mux.Handle("/mute", requireAPIKey(apiKey, http.HandlerFunc(a.muteHandler)))
+		mux.Handle("/mute/", requireAPIKey(apiKey, http.HandlerFunc(a.muteHandler)))
+		mux.Handle("/notify", requireAPIKey(apiKey, http.HandlerFunc(a.notifyHandler)))
+	}
+	return mux
+}
+
+// doorsHandler serves the monitor's view of every door as of the latest poll.
+func (a *App) doorsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	doors := a.latestDoors.list()
+	res := make([]doorResponse, 0, len(doors))
+	for _, d := range doors {
+		res = append(res, a.doorResponse(d))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// doorHandler serves GET /doors/{door}, fetching the door's state from its
+// controller rather than waiting for the next poll.
+func (a *App) doorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/doors/")
+	c, rawName := a.controllerFor(name)
+	if c == nil {
+		http.Error(w, "door not found", http.StatusNotFound)
+		return
+	}
+	state, err := getDoor(r.Context(), c.client, rawName, a.cfg.PollTimeout)
+	switch {
+	case errors.Is(err, errDoorNotFound):
+		http.Error(w, "door not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "controller unavailable: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if problem := malformedState(rawName, state); problem != "" {
+		http.Error(w, "controller sent an unusable state: "+problem, http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.doorResponse(doorView{
+		Name:       name,
+		Open:       state.SensorClosedState != state.State,
+		LastChange: state.LastStateChangeTimestamp,
+	}))
+}
+
+func (a *App) doorResponse(d doorView) doorResponse {
+	door := doorResponse{
+		Name:       d.Name,
+		State:      "closed",
+		LastChange: d.LastChange,
+		Muted:      a.mutes.isMuted(d.Name, a.clock.Now()),
+	}
+	if d.Open {
+		door.State = "open"
+		door.OpenForSeconds = int64(a.clock.Since(d.LastChange).Seconds())
+	}
+	return door
+}
+
+// muteHandler mutes a door with POST /mute and clears a mute with
+// DELETE /mute/{door}.
+func (a *App) muteHandler(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/mute":
+		req := &muteRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.Door == "" {
+			http.Error(w, "expected {\"door\": ..., \"duration\": ...}", http.StatusBadRequest)
+			return
+		}
+
+		until := time.Time{}
+		if req.Duration != "" {
+			d, err := time.ParseDuration(req.Duration)
+			if err != nil || d <= 0 {
+				http.Error(w, "invalid duration", http.StatusBadRequest)
+				return
+			}
+			until = a.clock.Now().Add(d)
+		}
+
+		a.mutes.muteUntil(req.Door, until)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/mute/"):
+		a.mutes.unmute(strings.TrimPrefix(r.URL.Path, "/mute/"))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// notifyHandler sends the message in a POST /notify request through the
+// configured notifiers, routed by its severity as any other message would be.
+func (a *App) notifyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !a.msgEnabled(MsgManual) {
+		http.Error(w, "manual notifications are disabled", http.StatusForbidden)
+		return
+	}
+
+	req := &notifyRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil || strings.TrimSpace(req.Message) == "" {
+		http.Error(w, "expected {\"message\": ..., \"severity\": ...}", http.StatusBadRequest)
+		return
+	}
+	severity := SeverityInfo
+	if req.Severity != "" {
+		var err error
+		if severity, err = parseSeverity(req.Severity); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Changed sets each message apart for deduplication and the queue, as
+	// they have no door state to do it.
+	a.deliver(r.Context(), Event{Type: MsgManual, Severity: severity, Changed: a.clock.Now()}, req.Message)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"porter/client"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiKey     string
+		method     string
+		path       string
+		body       string
+		key        string // sent in X-API-Key
+		wantStatus int
+	}{
+		{"doors without a key", "", "GET", "/doors", "", "", http.StatusOK},
+		{"mute without a key", "", "POST", "/mute", `{"door": "garage"}`, "", http.StatusNotFound},
+		{"unmute without a key", "", "DELETE", "/mute/garage", "", "", http.StatusNotFound},
+		{"notify without a key", "", "POST", "/notify", `{"message": "hi"}`, "", http.StatusNotFound},
+		{"doors with the key", "s3cret", "GET", "/doors", "", "s3cret", http.StatusOK},
+		{"doors with a wrong key", "s3cret", "GET", "/doors", "", "guess", http.StatusUnauthorized},
+		{"one door with a wrong key", "s3cret", "GET", "/doors/garage", "", "guess", http.StatusUnauthorized},
+		{"mute with the key", "s3cret", "POST", "/mute", `{"door": "garage"}`, "s3cret", http.StatusNoContent},
+		{"mute with a wrong key", "s3cret", "POST", "/mute", `{"door": "garage"}`, "", http.StatusUnauthorized},
+		{"unmute with the key", "s3cret", "DELETE", "/mute/garage", "", "s3cret", http.StatusNoContent},
+		{"bad mute duration", "s3cret", "POST", "/mute", `{"door": "garage", "duration": "soon"}`, "s3cret", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := NewApp(Config{}, nil, &recordingNotifier{}, nil)
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.key != "" {
+				r.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			app.apiHandler(tt.apiKey).ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("%s %s: HTTP %d, want %d", tt.method, tt.path, w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestMuteExpiry(t *testing.T) {
+	clock := newFakeClock(pollStart)
+	app := NewApp(Config{}, nil, &recordingNotifier{}, nil)
+	app.clock = clock
+	app.latestDoors.set([]doorView{{Name: "garage", Open: true, LastChange: pollStart}})
+	api := app.apiHandler("s3cret")
+
+	r := httptest.NewRequest("POST", "/mute", strings.NewReader(`{"door": "garage", "duration": "2h"}`))
+	r.Header.Set("X-API-Key", "s3cret")
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("POST /mute: HTTP %d", w.Code)
+	}
+
+	steps := []struct {
+		at        time.Duration
+		wantMuted bool
+	}{
+		{0, true},
+		{119 * time.Minute, true},
+		{2 * time.Hour, false},
+		{3 * time.Hour, false},
+	}
+	for _, step := range steps {
+		clock.Set(pollStart.Add(step.at))
+
+		r := httptest.NewRequest("GET", "/doors", nil)
+		r.Header.Set("X-API-Key", "s3cret")
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+
+		var doors []doorResponse
+		if err := json.NewDecoder(w.Body).Decode(&doors); err != nil {
+			t.Fatal(err)
+		}
+		if len(doors) != 1 || doors[0].Muted != step.wantMuted {
+			t.Errorf("after %v: doors %+v, want garage muted %v", step.at, doors, step.wantMuted)
+		}
+	}
+}
+
+// gettingPorter is a stubPorter that can fetch a single door.
+type gettingPorter struct {
+	stubPorter
+	gets []string
+}
+
+func (p *gettingPorter) GetContext(ctx context.Context, name string) (*client.DoorState, error) {
+	p.gets = append(p.gets, name)
+	doors, err := p.List()
+	if err != nil {
+		return nil, err
+	}
+	if state, ok := doors[name]; ok {
+		return state, nil
+	}
+	return nil, errDoorNotFound
+}
+
+func TestDoorLookup(t *testing.T) {
+	home := &stubPorter{}
+	home.set("garage", true, pollStart.Add(-time.Hour))
+	cabin := &gettingPorter{}
+	cabin.set("shed", false, pollStart.Add(-time.Minute))
+	down := &stubPorter{err: errors.New("connection refused")}
+
+	app := NewApp(Config{}, []*controller{{label: "home", client: home}, {label: "cabin", client: cabin}, {label: "barn", client: down}}, &recordingNotifier{}, nil)
+	app.clock = newFakeClock(pollStart)
+	api := app.apiHandler("")
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		want       doorResponse
+	}{
+		{"/doors/home/garage", http.StatusOK, doorResponse{Name: "home/garage", State: "open", LastChange: pollStart.Add(-time.Hour), OpenForSeconds: 3600}},
+		{"/doors/cabin/shed", http.StatusOK, doorResponse{Name: "cabin/shed", State: "closed", LastChange: pollStart.Add(-time.Minute)}},
+		{"/doors/home/shed", http.StatusNotFound, doorResponse{}},
+		{"/doors/cabin/garage", http.StatusNotFound, doorResponse{}},
+		{"/doors/attic/garage", http.StatusNotFound, doorResponse{}},
+		{"/doors/barn/gate", http.StatusBadGateway, doorResponse{}},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.wantStatus {
+			t.Errorf("GET %s: HTTP %d, want %d", tt.path, w.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+		var got doorResponse
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if !got.LastChange.Equal(tt.want.LastChange) || got.Name != tt.want.Name || got.State != tt.want.State || got.OpenForSeconds != tt.want.OpenForSeconds || got.Muted {
+			t.Errorf("GET %s = %+v, want %+v", tt.path, got, tt.want)
+		}
+	}
+
+	// Clients that can fetch one door are asked for it by its own name.
+	if want := []string{"shed", "garage"}; strings.Join(cabin.gets, ",") != strings.Join(want, ",") {
+		t.Errorf("cabin was asked for %q, want %q", cabin.gets, want)
+	}
+}
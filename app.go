@@ -0,0 +1,551 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/hako/durafmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Config holds the settings that control how doors are monitored and
+// reported, populated from flags.
+type Config struct {
+	OpenThreshold   time.Duration
+	RepeatThreshold time.Duration
+	PollInterval    time.Duration
+
+	// PollJitter varies each poll interval randomly by up to this fraction
+	// either way, so that several monitors don't poll in step.
+	PollJitter float64
+
+	// MaxRepeats caps repeat notifications per open event (0 for no limit).
+	MaxRepeats int
+
+	// RepeatBackoff doubles the repeat interval after each repeat, up to
+	// RepeatMax.
+	RepeatBackoff bool
+	RepeatMax     time.Duration
+
+	// CallThreshold is how long a door must be open before voiceNotifier
+	// is called (0 disables).
+	CallThreshold time.Duration
+
+	// StartupGrace holds back alerts for doors that were already open at
+	// startup for this long.
+	StartupGrace time.Duration
+
+	// CloseDebounce is how long a door must stay closed before the close is
+	// acted on.
+	CloseDebounce time.Duration
+
+	// PollTimeout bounds each request to a controller (0 for no limit).
+	PollTimeout time.Duration
+
+	// DoorThresholds override OpenThreshold for individual doors.
+	DoorThresholds map[string]time.Duration
+
+	// On OffDays and Holidays (dates as 2006-01-02), OffDayThreshold and
+	// OffDayDoorThresholds take precedence over the thresholds above. A zero
+	// OffDayThreshold leaves the everyday thresholds in effect.
+	OffDays              map[time.Weekday]bool
+	Holidays             map[string]bool
+	OffDayThreshold      time.Duration
+	OffDayDoorThresholds map[string]time.Duration
+
+	// DoorNames maps door names to the names shown in messages.
+	DoorNames map[string]string
+
+	// EscalateAfter is how many repeat notifications for the same open event
+	// go unanswered before the escalation notifier is included (0 disables).
+	EscalateAfter int
+
+	NotifyOnOpen bool
+	BatchNotify  bool
+
+	// A door changing state more than FlapCount times within FlapWindow is
+	// flapping (0 disables).
+	FlapCount  int
+	FlapWindow time.Duration
+
+	// MissingAfter is how long a door can be absent from its controller's
+	// list before it is reported missing (0 disables).
+	MissingAfter time.Duration
+
+	// MaxDailyOpen is how long a door may be open in total each day before
+	// a notice is sent (0 disables).
+	MaxDailyOpen time.Duration
+
+	// DigestAt is when the daily digest is sent, as an offset from midnight,
+	// or negative if disabled.
+	DigestAt time.Duration
+
+	MaxStateAge time.Duration
+	ClockAlert  bool
+
+	QuietHours *QuietHours
+	StateFile  string
+
+	// DisabledMsgs are message types that are never sent.
+	DisabledMsgs map[int]bool
+
+	TimeFormat   string
+	TimeLocation *time.Location
+}
+
+// repeatInterval is how long to wait before the next repeat notification when
+// repeats have already been sent for the current open event.
+func (c *Config) repeatInterval(repeats int) time.Duration {
+	if !c.RepeatBackoff {
+		return c.RepeatThreshold
+	}
+
+	interval := c.RepeatThreshold
+	for i := 0; i < repeats && interval < c.RepeatMax; i++ {
+		interval *= 2
+	}
+	if interval > c.RepeatMax {
+		interval = c.RepeatMax
+	}
+	return interval
+}
+
+// App monitors a set of controllers and delivers notifications about them.
+type App struct {
+	cfg         Config
+	controllers []*controller
+
+	notifier Notifier
+
+	// escalationNotifier additionally receives open notifications once a
+	// door has been repeated cfg.EscalateAfter times.
+	escalationNotifier Notifier
+
+	// criticalNotifier additionally receives critical messages.
+	criticalNotifier Notifier
+
+	// voiceNotifier phones recipients about doors open past
+	// cfg.CallThreshold.
+	voiceNotifier Notifier
+
+	// observers are told of every door transition.
+	observers []DoorObserver
+
+	// channels are the configured notifiers, each on its own, for
+	// -testnotify.
+	channels []Notifier
+
+	// inbound, if set, takes commands texted back by recipients.
+	inbound *inboundHandler
+
+	// reloadRecipients, if set, rereads -recipientsfile.
+	reloadRecipients func() error
+
+	// mutes are the doors silenced through texts or the API, and
+	// latestDoors is every door as of the latest poll.
+	mutes       *muteSet
+	latestDoors *doorSnapshot
+
+	clock Clock
+	rand  *rand.Rand
+
+	// langTemplates are the message templates for contacts' languages.
+	langTemplates map[string]map[int]*template.Template
+
+	// events, if set, records door events and notifications sent.
+	events EventStore
+
+	// auditLog, if set, records the outcome of every notification.
+	auditLog *auditLog
+
+	// queue, if set, holds notifications until they have been delivered.
+	queue *notifyQueue
+
+	opens openCounter
+
+	// inflight tracks notifications that are still being delivered so
+	// shutdown can wait for them.
+	inflight sync.WaitGroup
+
+	deferredMu sync.Mutex
+	deferred   []deferredMsg
+}
+
+func NewApp(cfg Config, controllers []*controller, notifier, escalationNotifier Notifier) *App {
+	if cfg.TimeLocation == nil {
+		cfg.TimeLocation = time.Local
+	}
+	return &App{
+		cfg:                cfg,
+		controllers:        controllers,
+		notifier:           notifier,
+		escalationNotifier: escalationNotifier,
+		clock:              realClock{},
+		mutes:              &muteSet{doors: make(map[string]time.Time)},
+		latestDoors:        &doorSnapshot{},
+	}
+}
+
+// pollInterval returns the time until the next poll, with jitter applied. The
+// random source is seeded from the clock, so that it is repeatable in tests.
+func (a *App) pollInterval() time.Duration {
+	if a.cfg.PollJitter <= 0 {
+		return a.cfg.PollInterval
+	}
+	if a.rand == nil {
+		a.rand = rand.New(rand.NewSource(a.clock.Now().UnixNano()))
+	}
+
+	jitter := (a.rand.Float64()*2 - 1) * a.cfg.PollJitter
+	return a.cfg.PollInterval + time.Duration(jitter*float64(a.cfg.PollInterval))
+}
+
+// run polls the controllers every cfg.PollInterval until ctx is cancelled.
+func (a *App) run(ctx context.Context) {
+	m := newMonitor(a)
+
+	ticker := a.clock.NewTicker(a.pollInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C():
+			// select picks at random when a tick and cancellation are both
+			// ready; don't start another poll once shutdown has begun.
+			if ctx.Err() != nil {
+				return
+			}
+			if a.cfg.PollJitter > 0 {
+				ticker.Reset(a.pollInterval())
+			}
+			a.flushDeferred(ctx)
+			a.retryQueued(ctx)
+			m.poll(ctx)
+			m.maybeSendDigest(ctx, a.clock.Now())
+		}
+	}
+}
+
+// pollOnce polls every controller a single time and waits for the resulting
+// notifications to be delivered.
+func (a *App) pollOnce(ctx context.Context) error {
+	a.retryQueued(ctx)
+	err := newMonitor(a).poll(ctx)
+	a.inflight.Wait()
+	return err
+}
+
+// sendTestNotification sends a test message through each notifier in turn and
+// returns a process exit code: 0 if every channel succeeded, 1 otherwise.
+func (a *App) sendTestNotification(notifiers []Notifier) int {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	ctx = withEvent(ctx, Event{Type: MsgTest})
+
+	msg := a.genMsg(MsgTest)
+	code := 0
+	for _, n := range notifiers {
+		if err := n.Send(ctx, msg); err != nil {
+			slog.Error("test notification failed", "channel", channelName(n), "error", err)
+			code = 1
+		} else {
+			slog.Info("test notification sent", "channel", channelName(n))
+		}
+	}
+	return code
+}
+
+// openThreshold returns the threshold for a door, looked up first by its
+// controller-qualified name and then by its name on the controller.
+func (a *App) openThreshold(doorName, rawName string) time.Duration {
+	if a.isOffDay(a.clock.Now()) {
+		if thresh, ok := a.cfg.OffDayDoorThresholds[doorName]; ok {
+			return thresh
+		}
+		if thresh, ok := a.cfg.OffDayDoorThresholds[rawName]; ok {
+			return thresh
+		}
+		if a.cfg.OffDayThreshold > 0 {
+			return a.cfg.OffDayThreshold
+		}
+	}
+	if thresh, ok := a.cfg.DoorThresholds[doorName]; ok {
+		return thresh
+	}
+	if thresh, ok := a.cfg.DoorThresholds[rawName]; ok {
+		return thresh
+	}
+	return a.cfg.OpenThreshold
+}
+
+// isOffDay reports whether t falls on a weekend day or holiday, where the
+// off-day thresholds apply.
+func (a *App) isOffDay(t time.Time) bool {
+	local := t.In(a.cfg.TimeLocation)
+	return a.cfg.OffDays[local.Weekday()] || a.cfg.Holidays[local.Format(dateLayout)]
+}
+
+func (a *App) genMsg(msgType int, values ...interface{}) string {
+	tmpl, ok := msgTemplates[msgType]
+	if !ok {
+		return ""
+	}
+	return a.renderMsg(tmpl, msgType, values...)
+}
+
+// localizeMsg renders a message in each language that has a template for it.
+func (a *App) localizeMsg(msgType int, values ...interface{}) map[string]string {
+	var msgs map[string]string
+	for lang, tmpls := range a.langTemplates {
+		if tmpl, ok := tmpls[msgType]; ok {
+			if msgs == nil {
+				msgs = make(map[string]string)
+			}
+			msgs[lang] = a.renderMsg(tmpl, msgType, values...)
+		}
+	}
+	return msgs
+}
+
+func (a *App) renderMsg(tmpl *template.Template, msgType int, values ...interface{}) string {
+	currentTime := a.clock.Now()
+	data := msgData{Time: currentTime.In(a.cfg.TimeLocation).Format(a.cfg.TimeFormat)}
+	if len(values) > 0 {
+		switch v := values[0].(type) {
+		case string:
+			data.DoorName = a.displayName(v)
+			data.OpensToday = a.opens.today(v, currentTime, a.cfg.TimeLocation)
+		case []batchDoor:
+			data.Doors = a.msgDoors(v)
+		case activitySummary:
+			data.Summary = v.String()
+		case digestSummary:
+			data.OpenEvents = v.OpenEvents
+			data.LongestDoor = a.displayName(v.LongestDoor)
+			data.Longest = durafmt.ParseShort(v.Longest).String()
+			data.Doors = a.msgDoors(v.StillOpen)
+		}
+	}
+	if len(values) > 1 {
+		if d, ok := values[1].(time.Duration); ok {
+			data.Duration = durafmt.ParseShort(d).String()
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		slog.Error("failed to render message", "type", eventName(msgType), "error", err)
+		return ""
+	}
+	return buf.String()
+}
+
+// msgEnabled reports whether messages of msgType may be sent. Disabling
+// open notifications covers batched ones too.
+func (a *App) msgEnabled(msgType int) bool {
+	if msgType == MsgBatchOpen && a.cfg.DisabledMsgs[MsgStateChangeOpen] {
+		return false
+	}
+	return !a.cfg.DisabledMsgs[msgType]
+}
+
+func (a *App) notify(ctx context.Context, msgType int, values ...interface{}) {
+	a.notifyEvent(ctx, Event{Type: msgType}, values...)
+}
+
+// notifyEscalated is like notify, but also delivers to escalationNotifier.
+func (a *App) notifyEscalated(ctx context.Context, msgType int, values ...interface{}) {
+	a.notifyEvent(ctx, Event{Type: msgType, Escalated: true}, values...)
+}
+
+func (a *App) notifyEvent(ctx context.Context, ev Event, values ...interface{}) {
+	msgType := ev.Type
+	if !a.msgEnabled(msgType) {
+		return
+	}
+	ev.Severity = msgSeverity(msgType)
+	if len(values) > 0 {
+		ev.DoorName, _ = values[0].(string)
+	}
+	if len(values) > 1 {
+		ev.Duration, _ = values[1].(time.Duration)
+	}
+	if len(values) > 2 {
+		ev.Changed, _ = values[2].(time.Time)
+	}
+
+	msg := a.genMsg(msgType, values...)
+	ev.Localized = a.localizeMsg(msgType, values...)
+
+	if a.cfg.QuietHours.suppresses(msgType, a.clock.Now().In(a.cfg.TimeLocation)) {
+		if a.cfg.QuietHours.Defer {
+			a.deferMsg(ev, msg)
+		}
+		return
+	}
+
+	a.deliver(ctx, ev, msg)
+}
+
+func (a *App) msgDoors(doors []batchDoor) []msgDoor {
+	var md []msgDoor
+	for _, d := range doors {
+		md = append(md, msgDoor{Name: a.displayName(d.Name), Duration: durafmt.ParseShort(d.Duration).String()})
+	}
+	return md
+}
+
+// displayName returns the name to show in messages for a door, looked up
+// first by its controller-qualified name and then by its name on the
+// controller. Doors without a display name keep their own.
+func (a *App) displayName(doorName string) string {
+	if name, ok := a.cfg.DoorNames[doorName]; ok {
+		return name
+	}
+	if label, raw, ok := strings.Cut(doorName, "/"); ok {
+		if name, ok := a.cfg.DoorNames[raw]; ok {
+			return label + "/" + name
+		}
+	}
+	return doorName
+}
+
+// batchDoor is a door due for an open notification, collected for
+// -batchnotify.
+type batchDoor struct {
+	Name      string
+	Duration  time.Duration
+	Escalated bool
+	Changed   time.Time
+}
+
+// notifyBatch sends a single open notification covering several doors,
+// split into parts if it would be too long for one SMS.
+func (a *App) notifyBatch(ctx context.Context, doors []batchDoor) {
+	if !a.msgEnabled(MsgBatchOpen) {
+		return
+	}
+
+	ev := Event{Type: MsgBatchOpen, Severity: msgSeverity(MsgBatchOpen)}
+	for _, d := range doors {
+		ev.Escalated = ev.Escalated || d.Escalated
+		ev.Doors = append(ev.Doors, d.Name)
+	}
+
+	msg := a.genMsg(MsgBatchOpen, doors)
+	ev.Localized = a.localizeMsg(MsgBatchOpen, doors)
+
+	if a.cfg.QuietHours.suppresses(MsgBatchOpen, a.clock.Now().In(a.cfg.TimeLocation)) {
+		if a.cfg.QuietHours.Defer {
+			a.deferMsg(ev, msg)
+		}
+		return
+	}
+
+	for i, part := range splitMessage(msg, twilioMaxBodyLength) {
+		ev.Part = i
+		a.deliver(ctx, ev, part)
+	}
+}
+
+// call places a voice call about a door that has been open too long. Calls are
+// urgent, so quiet hours don't apply.
+func (a *App) call(ctx context.Context, doorName string, d time.Duration) {
+	a.inflight.Add(1)
+	defer a.inflight.Done()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+	defer cancel()
+
+	ev := Event{Type: MsgStateChangeOpen, DoorName: doorName, Duration: d, Severity: SeverityCritical}
+	results := &deliveryResults{}
+	defer a.audit(ev, results)
+	ctx = withDeliveryResults(withEvent(ctx, ev), results)
+
+	logger := slog.With("door", doorName, "recipients", len(recipientsOf(a.voiceNotifier)))
+	if err := a.voiceNotifier.Send(ctx, a.genMsg(MsgStateChangeOpen, doorName, d)); err != nil {
+		logger.Error("failed to place call", "error", err)
+	} else {
+		logger.Info("call placed")
+	}
+}
+
+// deliver sends a message to the configured notifier. Deliveries are not
+// cut short when ctx is cancelled, only bounded by sendTimeout, so that a
+// notification already underway during shutdown still goes out. With a
+// queue, the message is kept there until it has been sent.
+func (a *App) deliver(ctx context.Context, ev Event, msg string) {
+	if a.queue == nil {
+		a.send(ctx, ev, msg)
+		return
+	}
+
+	a.sendQueued(ctx, a.queue.add(ev, msg, a.clock.Now()))
+}
+
+// send routes a message to the configured notifiers by its escalation and
+// severity.
+func (a *App) send(ctx context.Context, ev Event, msg string) error {
+	notifiers := []Notifier{a.notifier}
+	if ev.Escalated && a.escalationNotifier != nil {
+		notifiers = append(notifiers, a.escalationNotifier)
+	}
+	if ev.Severity == SeverityCritical && a.criticalNotifier != nil {
+		notifiers = append(notifiers, a.criticalNotifier)
+	}
+	n := notifiers[0]
+	if len(notifiers) > 1 {
+		n = &MultiNotifier{Notifiers: notifiers}
+	}
+
+	return a.deliverTo(ctx, n, ev, msg)
+}
+
+// doorChanged passes a door transition to the observers. Each is given up to
+// observerTimeout, even once shutdown has begun. Failures are only logged;
+// observers are not retried.
+func (a *App) doorChanged(ctx context.Context, change DoorChange) {
+	for _, o := range a.observers {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), observerTimeout)
+		err := o.DoorChanged(ctx, change)
+		cancel()
+		if err != nil {
+			name := ""
+			if n, ok := o.(Notifier); ok {
+				name = channelName(n)
+			}
+			slog.Warn("failed to report door state", "channel", name, "door", change.Door, "open", change.Open, "error", err)
+		}
+	}
+}
+
+// deliverTo sends a message through n, as deliver does.
+func (a *App) deliverTo(ctx context.Context, n Notifier, ev Event, msg string) error {
+	a.inflight.Add(1)
+	defer a.inflight.Done()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+	defer cancel()
+
+	results := &deliveryResults{}
+	defer a.audit(ev, results)
+
+	ctx = withDeliveryResults(withEvent(ctx, ev), results)
+	logger := slog.With("type", eventName(ev.Type), "door", ev.DoorName, "recipients", len(recipientsOf(n)))
+	err := n.Send(ctx, msg)
+	if err != nil {
+		logger.Error("failed to send notification", "error", err)
+	} else {
+		logger.Info("notification sent")
+		metrics.notificationSent(ev.Type)
+		a.recordEvent(EventRecord{Time: a.clock.Now(), Kind: "notify", Door: ev.DoorName, Type: eventName(ev.Type), Message: msg})
+	}
+	return err
+}
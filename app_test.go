@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"porter/client"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubPorter is a PorterClient serving door states set by the test.
+type stubPorter struct {
+	mu    sync.Mutex
+	doors map[string]*client.DoorState
+	err   error
+}
+
+// set reports doorName as open or closed since changed.
+func (s *stubPorter) set(doorName string, open bool, changed time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.doors == nil {
+		s.doors = make(map[string]*client.DoorState)
+	}
+	s.doors[doorName] = &client.DoorState{State: open, SensorClosedState: false, LastStateChangeTimestamp: changed}
+}
+
+func (s *stubPorter) List() (map[string]*client.DoorState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	doors := make(map[string]*client.DoorState, len(s.doors))
+	for name, state := range s.doors {
+		copied := *state
+		doors[name] = &copied
+	}
+	return doors, nil
+}
+
+// recordingNotifier keeps the messages sent through it.
+type recordingNotifier struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (r *recordingNotifier) Send(ctx context.Context, msg string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, msg)
+	return nil
+}
+
+func (r *recordingNotifier) sent() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.msgs...)
+}
+
+func TestAppPollOnce(t *testing.T) {
+	now := time.Now()
+	porter := &stubPorter{}
+	porter.set("garage", true, now.Add(-45*time.Minute))
+	porter.set("shed", true, now.Add(-5*time.Minute))
+	porter.set("gate", false, now.Add(-2*time.Hour))
+
+	cfg := Config{
+		OpenThreshold:   30 * time.Minute,
+		RepeatThreshold: time.Hour,
+		PollInterval:    time.Second,
+		TimeFormat:      time.Kitchen,
+		DoorThresholds:  map[string]time.Duration{"shed": time.Minute},
+	}
+	rec := &recordingNotifier{}
+	app := NewApp(cfg, []*controller{{client: porter}}, rec, nil)
+
+	if err := app.pollOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := rec.sent()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d notifications, want 2: %q", len(sent), sent)
+	}
+	for _, door := range []string{"garage", "shed"} {
+		found := false
+		for _, msg := range sent {
+			found = found || strings.Contains(msg, door)
+		}
+		if !found {
+			t.Errorf("no notification about %s: %q", door, sent)
+		}
+	}
+	if doors := app.latestDoors.list(); len(doors) != 3 {
+		t.Errorf("latest poll has %d doors, want 3", len(doors))
+	}
+}
+
+// shutdownObserver records the door openings it is told of, and how long it
+// was given for each. The first report cancels the poll, as a signal arriving
+// mid-poll would.
+type shutdownObserver struct {
+	cancel context.CancelFunc
+
+	doors   []string
+	expired []bool
+	budgets []time.Duration
+}
+
+func (o *shutdownObserver) DoorChanged(ctx context.Context, change DoorChange) error {
+	if !change.Open {
+		return nil
+	}
+	o.cancel()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Hour)
+	}
+	o.doors = append(o.doors, change.Door)
+	o.expired = append(o.expired, ctx.Err() != nil)
+	o.budgets = append(o.budgets, time.Until(deadline))
+	return nil
+}
+
+func TestDoorChangedDuringShutdown(t *testing.T) {
+	clock := newFakeClock(pollStart)
+	porter := &stubPorter{}
+	porter.set("garage", false, pollStart)
+	porter.set("shed", false, pollStart)
+
+	app := NewApp(Config{OpenThreshold: time.Hour, TimeFormat: time.Kitchen}, []*controller{{client: porter}}, &recordingNotifier{}, nil)
+	app.clock = clock
+	m := newMonitor(app)
+	if err := m.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	obs := &shutdownObserver{cancel: cancel}
+	app.observers = []DoorObserver{obs}
+	clock.Advance(time.Minute)
+	porter.set("garage", true, clock.Now())
+	porter.set("shed", true, clock.Now())
+	m.poll(ctx)
+
+	if len(obs.doors) != 2 {
+		t.Fatalf("observer told of %q opening, want both doors", obs.doors)
+	}
+	for i, door := range obs.doors {
+		if obs.expired[i] {
+			t.Errorf("%s was reported with an expired context", door)
+		}
+		if obs.budgets[i] <= 0 || obs.budgets[i] > observerTimeout {
+			t.Errorf("%s was reported with %v to go, want at most %v", door, obs.budgets[i], observerTimeout)
+		}
+	}
+}
+
+func TestOffDayThresholds(t *testing.T) {
+	cfg := Config{
+		OpenThreshold:        30 * time.Minute,
+		DoorThresholds:       map[string]time.Duration{"shed": 5 * time.Minute},
+		OffDays:              map[time.Weekday]bool{time.Saturday: true, time.Sunday: true},
+		Holidays:             map[string]bool{"2024-03-04": true},
+		OffDayThreshold:      2 * time.Hour,
+		OffDayDoorThresholds: map[string]time.Duration{"cabin/shed": 4 * time.Hour},
+		TimeLocation:         time.FixedZone("CET", 60*60),
+	}
+	// pollStart is 9:00 on Friday 1 March in CET.
+	day := func(days int, hours time.Duration) time.Time {
+		return pollStart.Add(time.Duration(days)*24*time.Hour + hours)
+	}
+
+	tests := []struct {
+		name           string
+		at             time.Time
+		door           string
+		noOffDayThresh bool
+		want           time.Duration
+	}{
+		{"weekday", day(0, 0), "home/garage", false, 30 * time.Minute},
+		{"weekday door threshold", day(0, 0), "home/shed", false, 5 * time.Minute},
+		{"late Friday UTC is Saturday locally", day(0, 15*time.Hour+30*time.Minute), "home/garage", false, 2 * time.Hour},
+		{"weekend", day(1, 0), "home/garage", false, 2 * time.Hour},
+		{"weekend overrides the everyday door threshold", day(1, 0), "home/shed", false, 2 * time.Hour},
+		{"weekend door threshold", day(2, 0), "cabin/shed", false, 4 * time.Hour},
+		{"holiday", day(3, 0), "home/garage", false, 2 * time.Hour},
+		{"after the holiday", day(4, 0), "home/garage", false, 30 * time.Minute},
+		{"no off-day threshold", day(1, 0), "home/garage", true, 30 * time.Minute},
+		{"no off-day threshold, door threshold", day(1, 0), "home/shed", true, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			if tt.noOffDayThresh {
+				cfg.OffDayThreshold = 0
+			}
+			app := NewApp(cfg, nil, nil, nil)
+			app.clock = newFakeClock(tt.at)
+			_, rawName, _ := strings.Cut(tt.door, "/")
+			if got := app.openThreshold(tt.door, rawName); got != tt.want {
+				t.Errorf("openThreshold(%s) at %v = %v, want %v", tt.door, tt.at.In(cfg.TimeLocation), got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditLog appends a JSON line per notification to a file, for tracking down
+// alerts that went missing.
+type auditLog struct {
+	path string
+	mu   sync.Mutex
+	f    *os.File
+}
+
+type auditEntry struct {
+	Time       time.Time      `json:"ts"`
+	Door       string         `json:"door,omitempty"`
+	Doors      []string       `json:"doors,omitempty"`
+	Event      string         `json:"event"`
+	Recipients []string       `json:"recipients,omitempty"`
+	Channels   []auditChannel `json:"channels"`
+}
+
+type auditChannel struct {
+	Channel    string   `json:"channel"`
+	Recipients []string `json:"recipients,omitempty"`
+	OK         bool     `json:"ok"`
+	Error      string   `json:"error,omitempty"`
+}
+
+func openAuditLog(path string) (*auditLog, error) {
+	l := &auditLog{path: path}
+	if err := l.reopen(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// reopen closes and reopens the file, so that it can be rotated.
+func (l *auditLog) reopen() error {
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		l.f.Close()
+	}
+	l.f = f
+	return nil
+}
+
+func (l *auditLog) write(entry auditEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("writing audit log: %w", err)
+	}
+	return l.f.Sync()
+}
+
+// AuditNotifier reports the outcome of each send through a channel to the
+// delivery results in the context, if any.
+type AuditNotifier struct {
+	Notifier Notifier
+}
+
+func (a *AuditNotifier) Send(ctx context.Context, msg string) error {
+	err := a.Notifier.Send(ctx, msg)
+	if r, ok := ctx.Value(deliveryResultsKey{}).(*deliveryResults); ok {
+		r.add(a.Notifier, err)
+	}
+	return err
+}
+
+type deliveryResultsKey struct{}
+
+type deliveryResults struct {
+	mu       sync.Mutex
+	channels []auditChannel
+}
+
+func (r *deliveryResults) add(n Notifier, err error) {
+	ch := auditChannel{Channel: channelName(n), OK: err == nil}
+	switch ch.Channel {
+	case "slack", "discord", "webhook", "ntfy":
+		// Their "recipients" are URLs, which may embed credentials.
+	default:
+		ch.Recipients = recipientsOf(n)
+	}
+	if err != nil {
+		ch.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.channels = append(r.channels, ch)
+}
+
+// withDeliveryResults returns a context in which AuditNotifiers collect
+// their results into r.
+func withDeliveryResults(ctx context.Context, r *deliveryResults) context.Context {
+	return context.WithValue(ctx, deliveryResultsKey{}, r)
+}
+
+// audit writes an audit log entry for a notification, if there is an audit
+// log.
+func (a *App) audit(ev Event, r *deliveryResults) {
+	if a.auditLog == nil {
+		return
+	}
+
+	entry := auditEntry{Time: a.clock.Now(), Door: ev.DoorName, Doors: ev.Doors, Event: eventName(ev.Type), Channels: r.channels}
+	if entry.Channels == nil {
+		entry.Channels = []auditChannel{}
+	}
+	seen := make(map[string]bool)
+	for _, ch := range entry.Channels {
+		for _, rcpt := range ch.Recipients {
+			if !seen[rcpt] {
+				seen[rcpt] = true
+				entry.Recipients = append(entry.Recipients, rcpt)
+			}
+		}
+	}
+
+	if err := a.auditLog.write(entry); err != nil {
+		slog.Error("failed to write audit log", "error", err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readAuditLog returns the entries in the audit log at path.
+func readAuditLog(t *testing.T, path string) []auditEntry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("audit line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestAuditLog(t *testing.T) {
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer slack.Close()
+	smtp := newFakeSMTP(t, "nobody@example.com")
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := openAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier := &MultiNotifier{Notifiers: []Notifier{
+		&AuditNotifier{Notifier: &SlackNotifier{WebhookURL: slack.URL}},
+		&AuditNotifier{Notifier: &EmailNotifier{Host: "127.0.0.1", Port: smtp.port(), From: "porter@example.com", To: []string{"nobody@example.com"}}},
+	}}
+	app := NewApp(Config{}, nil, notifier, nil)
+	app.clock = newFakeClock(pollStart)
+	app.auditLog = audit
+
+	app.send(context.Background(), Event{Type: MsgStateChangeOpen, DoorName: "garage"}, "garage has been open for 30 minutes")
+
+	entries := readAuditLog(t, path)
+	if len(entries) != 1 {
+		t.Fatalf("got %d audit entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if !e.Time.Equal(pollStart) || e.Door != "garage" || e.Event != eventName(MsgStateChangeOpen) {
+		t.Errorf("entry = %+v", e)
+	}
+	if len(e.Recipients) != 1 || e.Recipients[0] != "nobody@example.com" {
+		t.Errorf("recipients %q, want only the email address", e.Recipients)
+	}
+
+	channels := make(map[string]auditChannel)
+	for _, ch := range e.Channels {
+		channels[ch.Channel] = ch
+	}
+	if ch := channels["slack"]; !ch.OK || ch.Error != "" || len(ch.Recipients) != 0 {
+		t.Errorf("slack = %+v, want OK with its URL left out", ch)
+	}
+	if ch := channels["email"]; ch.OK || ch.Error == "" {
+		t.Errorf("email = %+v, want the rejection recorded", ch)
+	}
+
+	// After rotation, entries go to a new file at the same path.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := audit.reopen(); err != nil {
+		t.Fatal(err)
+	}
+	app.send(context.Background(), Event{Type: MsgStateChangeClosed, DoorName: "garage"}, "garage was closed")
+	if n := len(readAuditLog(t, path)); n != 1 {
+		t.Errorf("got %d entries after reopening, want 1", n)
+	}
+	if n := len(readAuditLog(t, path+".1")); n != 1 {
+		t.Errorf("rotated file has %d entries, want 1", n)
+	}
+}
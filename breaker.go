@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var errBreakerOpen = errors.New("channel disabled after repeated failures")
+
+// BreakerNotifier stops calling a failing notifier for a while. After
+// Threshold consecutive failures it fails fast for Cooldown, then lets a
+// single send through to probe whether the channel has recovered.
+type BreakerNotifier struct {
+	Notifier  Notifier
+	Threshold int
+	Cooldown  time.Duration
+	Clock     Clock // the host's if unset
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *BreakerNotifier) Send(ctx context.Context, msg string) error {
+	if !b.allow() {
+		return errBreakerOpen
+	}
+
+	err := b.Notifier.Send(ctx, msg)
+	b.record(err)
+	return err
+}
+
+func (b *BreakerNotifier) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.Threshold {
+		return true
+	}
+	if b.probing || clockOrReal(b.Clock).Now().Before(b.openUntil) {
+		return false
+	}
+
+	b.probing = true
+	slog.Info("circuit breaker half-open, probing channel", "channel", channelName(b.Notifier))
+	return true
+}
+
+func (b *BreakerNotifier) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasOpen := b.failures >= b.Threshold
+	b.probing = false
+
+	if err == nil {
+		if wasOpen {
+			slog.Info("circuit breaker closed, channel recovered", "channel", channelName(b.Notifier))
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.Threshold {
+		b.openUntil = clockOrReal(b.Clock).Now().Add(b.Cooldown)
+		if wasOpen {
+			slog.Warn("circuit breaker probe failed, channel stays disabled", "channel", channelName(b.Notifier), "cooldown", b.Cooldown)
+		} else {
+			slog.Warn("circuit breaker open, skipping channel", "channel", channelName(b.Notifier), "failures", b.failures, "cooldown", b.Cooldown)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreakerNotifier(t *testing.T) {
+	down := errors.New("down")
+	clock := newFakeClock(pollStart)
+	// The channel fails four times, then recovers.
+	inner := &scriptedNotifier{errs: []error{down, down, down, down}}
+	b := &BreakerNotifier{Notifier: inner, Threshold: 3, Cooldown: 5 * time.Minute, Clock: clock}
+
+	steps := []struct {
+		at        time.Duration
+		wantErr   error
+		wantCalls int
+	}{
+		{0, down, 1},
+		{time.Second, down, 2},
+		{2 * time.Second, down, 3}, // opens until 5m2s
+		{3 * time.Second, errBreakerOpen, 3},
+		{5*time.Minute + time.Second, errBreakerOpen, 3},
+		{5*time.Minute + 2*time.Second, down, 4}, // the probe fails
+		{6 * time.Minute, errBreakerOpen, 4},
+		{10*time.Minute + 2*time.Second, nil, 5}, // the probe succeeds
+		{10*time.Minute + 3*time.Second, nil, 6},
+	}
+	for _, step := range steps {
+		clock.Set(pollStart.Add(step.at))
+		if err := b.Send(context.Background(), "hello"); !errors.Is(err, step.wantErr) {
+			t.Errorf("after %v: Send() error = %v, want %v", step.at, err, step.wantErr)
+		}
+		if got := inner.count(); got != step.wantCalls {
+			t.Errorf("after %v: channel called %d times, want %d", step.at, got, step.wantCalls)
+		}
+	}
+}
+
+// gateNotifier blocks each send until the test releases it.
+type gateNotifier struct {
+	entered chan struct{}
+	release chan error
+}
+
+func (g *gateNotifier) Send(ctx context.Context, msg string) error {
+	g.entered <- struct{}{}
+	return <-g.release
+}
+
+func TestBreakerSingleProbe(t *testing.T) {
+	clock := newFakeClock(pollStart)
+	gate := &gateNotifier{entered: make(chan struct{}), release: make(chan error)}
+	b := &BreakerNotifier{Notifier: gate, Threshold: 1, Cooldown: time.Minute, Clock: clock}
+
+	go func() { <-gate.entered; gate.release <- errors.New("down") }()
+	b.Send(context.Background(), "fails")
+
+	clock.Advance(time.Minute)
+	probe := make(chan error)
+	go func() { probe <- b.Send(context.Background(), "probe") }()
+	<-gate.entered
+
+	// While the probe is out, everything else fails fast.
+	if err := b.Send(context.Background(), "during probe"); !errors.Is(err, errBreakerOpen) {
+		t.Errorf("Send() during the probe = %v, want %v", err, errBreakerOpen)
+	}
+
+	gate.release <- nil
+	if err := <-probe; err != nil {
+		t.Fatal(err)
+	}
+	go func() { <-gate.entered; gate.release <- nil }()
+	if err := b.Send(context.Background(), "after recovery"); err != nil {
+		t.Errorf("Send() after recovery = %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var errSMSBudget = errors.New("daily SMS limit reached")
+
+// smsBudget caps how many SMS are sent per day, counting from local midnight.
+// A nil budget never runs out.
+type smsBudget struct {
+	limit int
+	loc   *time.Location
+	clock Clock
+
+	// onExhausted, when set, is called once each day the limit is reached.
+	onExhausted func()
+
+	mu    sync.Mutex
+	day   string
+	count int
+}
+
+func newSMSBudget(limit int, loc *time.Location, clock Clock) *smsBudget {
+	return &smsBudget{limit: limit, loc: loc, clock: clock}
+}
+
+// take uses up one message, reporting false if none are left today. The
+// message that uses up the budget calls onExhausted before returning, so that
+// the notice is sent while the delivery that triggered it is still in flight.
+func (b *smsBudget) take() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	day := b.clock.Now().In(b.loc).Format("2006-01-02")
+	if day != b.day {
+		b.day = day
+		b.count = 0
+	}
+
+	if b.count >= b.limit {
+		b.mu.Unlock()
+		return false
+	}
+	b.count++
+	exhausted := b.count == b.limit
+	b.mu.Unlock()
+
+	if exhausted {
+		slog.Warn("daily SMS limit reached, suppressing further texts until midnight", "limit", b.limit)
+		if b.onExhausted != nil {
+			b.onExhausted()
+		}
+	}
+	return true
+}
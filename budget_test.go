@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSMSBudget(t *testing.T) {
+	clock := newFakeClock(pollStart)
+	b := newSMSBudget(2, time.UTC, clock)
+	exhausted := 0
+	b.onExhausted = func() { exhausted++ }
+
+	for i, want := range []bool{true, true, false, false} {
+		if got := b.take(); got != want {
+			t.Fatalf("take %d = %v, want %v", i+1, got, want)
+		}
+	}
+	if exhausted != 1 {
+		t.Fatalf("onExhausted called %d times, want 1", exhausted)
+	}
+
+	// The budget resets at local midnight.
+	clock.Set(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC))
+	if !b.take() || !b.take() || b.take() {
+		t.Fatal("budget didn't reset at midnight")
+	}
+	if exhausted != 2 {
+		t.Fatalf("onExhausted called %d times over two days, want 2", exhausted)
+	}
+
+	var none *smsBudget
+	if !none.take() {
+		t.Fatal("nil budget ran out")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when the test sets it. Its tickers
+// fire as the time passes their next tick, dropping ticks nobody has read as
+// a time.Ticker does.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Since(t time.Time) time.Duration { return c.Now().Sub(t) }
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clock: c, c: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Set moves the clock to now, firing any tickers that have come due.
+func (c *fakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+	for _, t := range c.tickers {
+		for !t.stopped && !t.next.After(now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+// Advance moves the clock on by d.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+type fakeTicker struct {
+	clock   *fakeClock
+	c       chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.period, t.next, t.stopped = d, t.clock.now.Add(d), false
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	clock := newFakeClock(time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC))
+	ticker := clock.NewTicker(time.Minute)
+
+	clock.Advance(59 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticked early")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case <-ticker.C():
+	default:
+		t.Fatal("didn't tick after a minute")
+	}
+
+	ticker.Stop()
+	clock.Advance(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticked after Stop")
+	default:
+	}
+}
+
+func TestOpenThresholdAndRepeat(t *testing.T) {
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	porter := &stubPorter{}
+	porter.set("garage", true, start)
+
+	rec := &recordingNotifier{}
+	cfg := Config{OpenThreshold: 30 * time.Minute, RepeatThreshold: time.Hour, PollInterval: time.Minute, TimeFormat: time.Kitchen}
+	app := NewApp(cfg, []*controller{{client: porter}}, rec, nil)
+	app.clock = clock
+	m := newMonitor(app)
+
+	steps := []struct {
+		at        time.Duration // since the door opened
+		wantSends int
+	}{
+		{0, 0},
+		{29 * time.Minute, 0},
+		{30 * time.Minute, 1}, // threshold
+		{31 * time.Minute, 1},
+		{89 * time.Minute, 1},
+		{90 * time.Minute, 2}, // first repeat
+		{150 * time.Minute, 3},
+	}
+	for _, step := range steps {
+		clock.Set(start.Add(step.at))
+		if err := m.poll(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(rec.sent()); got != step.wantSends {
+			t.Fatalf("after %v: %d notifications sent, want %d", step.at, got, step.wantSends)
+		}
+	}
+}
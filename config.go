@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// applyConfigFile sets flags from a JSON or YAML file whose keys are flag
+// names, e.g. {"twsid": "AC...", "openthresh": 15}. Flags given on the
+// command line take precedence over the file. Lists may be written as arrays
+// or as comma-separated strings.
+//
+// Only flat YAML is understood: "key: value" pairs, "- item" lists under a
+// key, and # comments.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	var values map[string]string
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".json" || (ext != ".yaml" && ext != ".yml" && bytes.HasPrefix(bytes.TrimSpace(b), []byte("{"))) {
+		values, err = parseJSONConfig(b)
+	} else {
+		values, err = parseYAMLConfig(b)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing config file: %w", err)
+	}
+
+	set := setFlags(fs)
+
+	for name, value := range values {
+		if name == "config" {
+			continue
+		}
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("config file: unknown option %q", name)
+		}
+		if set[name] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("config file: invalid value for %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func parseJSONConfig(b []byte) (map[string]string, error) {
+	raw := make(map[string]interface{})
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for name, v := range raw {
+		s, err := configString(v)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", name, err)
+		}
+		values[name] = s
+	}
+	return values, nil
+}
+
+func configString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := configString(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
+
+func parseYAMLConfig(b []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	var listKey string
+	var list []string
+
+	flushList := func() {
+		if listKey != "" {
+			values[listKey] = strings.Join(list, ",")
+		}
+		listKey, list = "", nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(stripYAMLComment(scanner.Text()))
+		if line == "" || line == "---" {
+			continue
+		}
+
+		if item, ok := strings.CutPrefix(line, "- "); ok {
+			if listKey == "" {
+				return nil, fmt.Errorf("line %d: list item without a key", lineNo)
+			}
+			list = append(list, unquoteYAML(item))
+			continue
+		}
+
+		flushList()
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected 'key: value'", lineNo)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if value == "" {
+			listKey = key
+			continue
+		}
+		values[key] = unquoteYAML(value)
+	}
+	flushList()
+
+	return values, scanner.Err()
+}
+
+// stripYAMLComment removes a trailing # comment that isn't inside quotes.
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquoteYAML(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		if s[0] == '"' {
+			if u, err := strconv.Unquote(s); err == nil {
+				return u
+			}
+		}
+		return s[1 : len(s)-1]
+	}
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		items := strings.Split(s[1:len(s)-1], ",")
+		for i := range items {
+			items[i] = unquoteYAML(items[i])
+		}
+		return strings.Join(items, ",")
+	}
+	return s
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseYAMLConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "scalars",
+			yaml: "---\nopenthresh: 15\ntwsid: AC123 # account\nverbose: true\n",
+			want: map[string]string{"openthresh": "15", "twsid": "AC123", "verbose": "true"},
+		},
+		{
+			name: "quoted",
+			yaml: "msgopen: \"{{.DoorName}} is open # still\"\nquietstart: '22:00'\n",
+			want: map[string]string{"msgopen": "{{.DoorName}} is open # still", "quietstart": "22:00"},
+		},
+		{
+			name: "lists",
+			yaml: "recipients:\n  - +18005550199\n  - \"+18005550100\"\n# done\ndoorthresh: [garage=5, 'shed=30']\n",
+			want: map[string]string{"recipients": "+18005550199,+18005550100", "doorthresh": "garage=5,shed=30"},
+		},
+		{
+			name:    "list item without a key",
+			yaml:    "- +18005550199\n",
+			wantErr: true,
+		},
+		{
+			name:    "not key: value",
+			yaml:    "openthresh 15\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAMLConfig([]byte(tt.yaml))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "values",
+			json: `{"openthresh": 15, "interval": 2.5, "verbose": true, "twsid": "AC123", "recipients": ["+18005550199", "+18005550100"]}`,
+			want: map[string]string{"openthresh": "15", "interval": "2.5", "verbose": "true", "twsid": "AC123", "recipients": "+18005550199,+18005550100"},
+		},
+		{name: "object value", json: `{"twsid": {"a": 1}}`, wantErr: true},
+		{name: "null", json: `{"twsid": null}`, wantErr: true},
+		{name: "malformed", json: `{"twsid": `, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJSONConfig([]byte(tt.json))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		contents string
+		args     []string
+		want     map[string]string
+		wantErr  bool
+	}{
+		{
+			name:     "yaml",
+			file:     "reporter.yaml",
+			contents: "openthresh: 20\ntwsid: AC123\n",
+			want:     map[string]string{"openthresh": "20", "twsid": "AC123"},
+		},
+		{
+			name:     "json by extension",
+			file:     "reporter.json",
+			contents: `{"openthresh": 20}`,
+			want:     map[string]string{"openthresh": "20", "twsid": ""},
+		},
+		{
+			name:     "json by content",
+			file:     "reporter.conf",
+			contents: ` {"twsid": "AC123"}`,
+			want:     map[string]string{"openthresh": "15", "twsid": "AC123"},
+		},
+		{
+			name:     "command line wins",
+			file:     "reporter.yaml",
+			contents: "openthresh: 20\ntwsid: AC123\n",
+			args:     []string{"-openthresh", "5"},
+			want:     map[string]string{"openthresh": "5", "twsid": "AC123"},
+		},
+		{
+			name:     "config key is ignored",
+			file:     "reporter.yaml",
+			contents: "config: other.yaml\n",
+			want:     map[string]string{"openthresh": "15", "twsid": ""},
+		},
+		{name: "unknown option", file: "reporter.yaml", contents: "openthreshold: 20\n", wantErr: true},
+		{name: "invalid value", file: "reporter.yaml", contents: "openthresh: soon\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			openThresh := fs.Int("openthresh", 15, "")
+			twSID := fs.String("twsid", "", "")
+			fs.String("config", "", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.contents), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			err := applyConfigFile(fs, path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := map[string]string{"openthresh": strconv.Itoa(*openThresh), "twsid": *twSID}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyConfigFileMissing(t *testing.T) {
+	if err := applyConfigFile(flag.NewFlagSet("test", flag.ContinueOnError), filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("missing config file was not an error")
+	}
+}
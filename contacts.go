@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Contact is a recipient who prefers to be reached on a particular channel.
+type Contact struct {
+	Channel string // "sms", "email" or "telegram"
+	Address string // phone number, email address or Telegram chat ID
+
+	// Quiet holds back door messages to this contact during these hours,
+	// regardless of the global quiet hours.
+	Quiet *QuietHours
+
+	// Lang selects the contact's message templates from -langdir.
+	Lang string
+}
+
+// personal reports whether the contact has settings of their own, and so
+// must be sent to separately from the rest of the channel's recipients.
+func (c Contact) personal() bool {
+	return c.Quiet != nil || c.Lang != ""
+}
+
+// parseContact parses a contact in the form 'channel:address', e.g.
+// 'sms:+18005550199' or 'telegram:12345', optionally followed by settings
+// such as ';quiet=22:00-07:00;lang=es'. Phone numbers are normalized as for
+// -recipients.
+func parseContact(s, region string) (Contact, error) {
+	spec, options, _ := strings.Cut(strings.TrimSpace(s), ";")
+	channel, address, ok := strings.Cut(spec, ":")
+	address = strings.TrimSpace(address)
+	if !ok || address == "" {
+		return Contact{}, fmt.Errorf("invalid contact %q, expected 'channel:address'", s)
+	}
+
+	c := Contact{Channel: strings.ToLower(channel), Address: address}
+	switch c.Channel {
+	case "sms":
+		number, err := normalizePhone(address, region)
+		if err != nil {
+			return Contact{}, fmt.Errorf("contact %q: %w", s, err)
+		}
+		c.Address = number
+	case "email":
+		if !strings.Contains(address, "@") {
+			return Contact{}, fmt.Errorf("contact %q: invalid email address", s)
+		}
+	case "telegram":
+	default:
+		return Contact{}, fmt.Errorf("contact %q: unknown channel %q, expected sms, email or telegram", s, channel)
+	}
+
+	for _, option := range strings.Split(options, ";") {
+		if strings.TrimSpace(option) == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(option, "=")
+		switch strings.TrimSpace(key) {
+		case "quiet":
+			from, to, ok := strings.Cut(value, "-")
+			if !ok {
+				return Contact{}, fmt.Errorf("contact %q: invalid quiet hours %q, expected HH:MM-HH:MM", s, value)
+			}
+			start, err := parseClock(strings.TrimSpace(from))
+			if err != nil {
+				return Contact{}, fmt.Errorf("contact %q: %w", s, err)
+			}
+			end, err := parseClock(strings.TrimSpace(to))
+			if err != nil {
+				return Contact{}, fmt.Errorf("contact %q: %w", s, err)
+			}
+			c.Quiet = &QuietHours{Start: start, End: end}
+		case "lang":
+			c.Lang = strings.ToLower(strings.TrimSpace(value))
+		default:
+			return Contact{}, fmt.Errorf("contact %q: unknown setting %q, expected quiet or lang", s, key)
+		}
+	}
+
+	return c, nil
+}
+
+func parseContacts(list []string, region string) ([]Contact, error) {
+	var contacts []Contact
+	for _, s := range list {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		c, err := parseContact(s, region)
+		if err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, c)
+	}
+	return contacts, nil
+}
+
+// contactAddresses returns the addresses of the contacts on channel that
+// have no settings of their own.
+func contactAddresses(contacts []Contact, channel string) []string {
+	var addresses []string
+	for _, c := range contacts {
+		if c.Channel == channel && !c.personal() {
+			addresses = append(addresses, c.Address)
+		}
+	}
+	return addresses
+}
+
+// ContactNotifier sends to a single contact, applying their own quiet hours
+// and language.
+type ContactNotifier struct {
+	Notifier Notifier
+	Contact  Contact
+
+	// Clock and Location are the App's, which quiet hours are reckoned by.
+	// The host's are used if they aren't set.
+	Clock    Clock
+	Location *time.Location
+}
+
+func (c *ContactNotifier) now() time.Time {
+	now := clockOrReal(c.Clock).Now()
+	if c.Location != nil {
+		now = now.In(c.Location)
+	}
+	return now
+}
+
+func (c *ContactNotifier) Send(ctx context.Context, msg string) error {
+	ev, ok := eventFromContext(ctx)
+	if !ok {
+		return c.Notifier.Send(ctx, msg)
+	}
+
+	if c.Contact.Quiet.suppresses(ev.Type, c.now()) {
+		return nil
+	}
+	if text, ok := ev.Localized[c.Contact.Lang]; ok && c.Contact.Lang != "" {
+		if ev.Part > 0 {
+			// The whole translation went out with the first part.
+			return nil
+		}
+		msg = text
+	}
+	return c.Notifier.Send(ctx, msg)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DedupNotifier passes each logical event on at most once within Window, so
+// that the same alert isn't delivered twice through one channel.
+type DedupNotifier struct {
+	Notifier Notifier
+	Window   time.Duration
+	Clock    Clock // the host's if unset
+
+	mu   sync.Mutex
+	sent map[string]time.Time
+}
+
+// dedupKey identifies an event by its type, door(s) and the state change it
+// is about.
+func dedupKey(ev Event) string {
+	return fmt.Sprintf("%s|%s|%v|%d|%d", eventName(ev.Type), ev.DoorName, ev.Doors, ev.Part, ev.Changed.UnixNano())
+}
+
+func (d *DedupNotifier) Send(ctx context.Context, msg string) error {
+	ev, ok := eventFromContext(ctx)
+	if !ok {
+		return d.Notifier.Send(ctx, msg)
+	}
+	key := dedupKey(ev)
+
+	d.mu.Lock()
+	now := clockOrReal(d.Clock).Now()
+	for k, at := range d.sent {
+		if now.Sub(at) >= d.Window {
+			delete(d.sent, k)
+		}
+	}
+	if _, dup := d.sent[key]; dup {
+		d.mu.Unlock()
+		return nil
+	}
+	if d.sent == nil {
+		d.sent = make(map[string]time.Time)
+	}
+	d.sent[key] = now
+	d.mu.Unlock()
+
+	err := d.Notifier.Send(ctx, msg)
+	if err != nil {
+		// Let a retry of a failed event through.
+		d.mu.Lock()
+		delete(d.sent, key)
+		d.mu.Unlock()
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDedupKey(t *testing.T) {
+	changed := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	base := Event{Type: MsgStateChangeOpen, DoorName: "garage", Changed: changed, Duration: time.Minute}
+
+	tests := []struct {
+		name string
+		ev   Event
+		same bool
+	}{
+		{"identical", base, true},
+		{"repeat of the same open event", Event{Type: MsgStateChangeOpen, DoorName: "garage", Changed: changed, Duration: time.Hour, Escalated: true}, true},
+		{"other door", Event{Type: MsgStateChangeOpen, DoorName: "shed", Changed: changed}, false},
+		{"other type", Event{Type: MsgStateChangeClosed, DoorName: "garage", Changed: changed}, false},
+		{"later open event", Event{Type: MsgStateChangeOpen, DoorName: "garage", Changed: changed.Add(time.Second)}, false},
+		{"batch part", Event{Type: MsgStateChangeOpen, DoorName: "garage", Changed: changed, Part: 1}, false},
+		{"batch doors", Event{Type: MsgStateChangeOpen, DoorName: "garage", Changed: changed, Doors: []string{"garage", "shed"}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := dedupKey(tt.ev) == dedupKey(base); got != tt.same {
+			t.Errorf("%s: same key = %v, want %v (%q vs %q)", tt.name, got, tt.same, dedupKey(tt.ev), dedupKey(base))
+		}
+	}
+}
+
+func TestDedupNotifier(t *testing.T) {
+	ev := Event{Type: MsgStateChangeOpen, DoorName: "garage", Changed: pollStart}
+	ctx := withEvent(context.Background(), ev)
+
+	inner := &scriptedNotifier{errs: []error{nil, errors.New("down"), nil}}
+	d := &DedupNotifier{Notifier: inner, Window: time.Minute, Clock: newFakeClock(pollStart)}
+
+	d.Send(ctx, "first")
+	d.Send(ctx, "duplicate")
+	if got := inner.count(); got != 1 {
+		t.Fatalf("duplicate within the window was sent: %d sends", got)
+	}
+
+	other := withEvent(context.Background(), Event{Type: MsgStateChangeOpen, DoorName: "shed", Changed: ev.Changed})
+	if err := d.Send(other, "other door"); err == nil {
+		t.Fatal("expected the scripted failure")
+	}
+	d.Send(other, "retry")
+	if got := inner.count(); got != 3 {
+		t.Errorf("retry of a failed send was held back: %d sends, want 3", got)
+	}
+
+	d.Send(context.Background(), "no event")
+	if got := inner.count(); got != 4 {
+		t.Errorf("message without an event was held back: %d sends, want 4", got)
+	}
+}
+
+func TestDedupWindowExpiry(t *testing.T) {
+	clock := newFakeClock(pollStart)
+	ctx := withEvent(context.Background(), Event{Type: MsgStateChangeOpen, DoorName: "garage", Changed: pollStart})
+	inner := &scriptedNotifier{}
+	d := &DedupNotifier{Notifier: inner, Window: time.Minute, Clock: clock}
+
+	steps := []struct {
+		at        time.Duration
+		wantSends int
+	}{
+		{0, 1},
+		{30 * time.Second, 1},
+		{59 * time.Second, 1},
+		{time.Minute, 2}, // the window is up
+		{time.Minute + 59*time.Second, 2},
+		{2 * time.Minute, 3},
+	}
+	for _, step := range steps {
+		clock.Set(pollStart.Add(step.at))
+		if err := d.Send(ctx, "garage has been open"); err != nil {
+			t.Fatal(err)
+		}
+		if got := inner.count(); got != step.wantSends {
+			t.Errorf("after %v: %d sends, want %d", step.at, got, step.wantSends)
+		}
+	}
+}
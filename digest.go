@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// digestStats accumulates activity between daily digests.
+type digestStats struct {
+	openEvents  int
+	longest     time.Duration
+	longestDoor string
+}
+
+// digestSummary is what a digest message reports.
+type digestSummary struct {
+	OpenEvents  int
+	Longest     time.Duration
+	LongestDoor string
+	StillOpen   []batchDoor
+}
+
+func (s *digestStats) recordOpen() {
+	s.openEvents++
+}
+
+func (s *digestStats) recordOpenDuration(doorName string, d time.Duration) {
+	if d > s.longest {
+		s.longest = d
+		s.longestDoor = doorName
+	}
+}
+
+// nextOccurrence returns the first time after now that is at the given offset
+// from midnight in loc.
+func nextOccurrence(now time.Time, at time.Duration, loc *time.Location) time.Time {
+	local := now.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	next := midnight.Add(at)
+	if !next.After(now) {
+		next = midnight.AddDate(0, 0, 1).Add(at)
+	}
+	return next
+}
+
+// maybeSendDigest sends the daily digest once its scheduled time has passed.
+func (m *monitor) maybeSendDigest(ctx context.Context, now time.Time) {
+	cfg := &m.app.cfg
+	if cfg.DigestAt < 0 {
+		return
+	}
+	if m.nextDigest.IsZero() {
+		m.nextDigest = nextOccurrence(now, cfg.DigestAt, cfg.TimeLocation)
+	}
+	if now.Before(m.nextDigest) {
+		return
+	}
+
+	summary := digestSummary{
+		OpenEvents:  m.digest.openEvents,
+		Longest:     m.digest.longest,
+		LongestDoor: m.digest.longestDoor,
+	}
+	for _, c := range m.controllers {
+		for _, v := range c.views {
+			if !v.Open {
+				continue
+			}
+			d := now.Sub(v.LastChange)
+			summary.StillOpen = append(summary.StillOpen, batchDoor{Name: v.Name, Duration: d})
+			if d > summary.Longest {
+				summary.Longest = d
+				summary.LongestDoor = v.Name
+			}
+		}
+	}
+
+	m.app.notify(ctx, MsgDigest, summary)
+
+	m.digest = digestStats{}
+	m.nextDigest = nextOccurrence(now, cfg.DigestAt, cfg.TimeLocation)
+}
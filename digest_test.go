@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigestMessage(t *testing.T) {
+	app := NewApp(Config{TimeFormat: time.Kitchen}, nil, nil, nil)
+
+	tests := []struct {
+		summary digestSummary
+		want    string
+	}{
+		{digestSummary{}, "0 door openings. All doors are closed."},
+		{digestSummary{OpenEvents: 1, Longest: 5 * time.Minute, LongestDoor: "garage"}, "1 door opening, longest was garage at 5 minutes. All doors are closed."},
+		{digestSummary{OpenEvents: 2, Longest: time.Hour, LongestDoor: "shed", StillOpen: []batchDoor{{Name: "shed", Duration: time.Hour}}}, "2 door openings, longest was shed at 1 hour. Still open: shed (1 hour)."},
+	}
+
+	for _, tt := range tests {
+		got := app.genMsg(MsgDigest, tt.summary)
+		if !strings.HasSuffix(got, "Porter daily summary: "+tt.want) {
+			t.Errorf("genMsg(MsgDigest, %+v) = %q, want it to end %q", tt.summary, got, tt.want)
+		}
+	}
+}
+
+func TestDailyDigest(t *testing.T) {
+	const summary = "Porter daily summary: "
+
+	clock := newFakeClock(pollStart)
+	porter := &stubPorter{}
+	rec := &recordingNotifier{}
+	cfg := Config{OpenThreshold: 12 * time.Hour, PollInterval: time.Minute, TimeFormat: time.Kitchen, DigestAt: 8 * time.Hour, TimeLocation: time.UTC}
+	app := NewApp(cfg, []*controller{{client: porter}}, rec, nil)
+	app.clock = clock
+	m := newMonitor(app)
+
+	steps := []struct {
+		at    time.Duration // from pollStart, 08:00
+		doors []stubDoor
+		want  string // the digest sent, if any
+	}{
+		{at: 0, doors: []stubDoor{{name: "garage"}, {name: "shed"}}},
+		{at: time.Hour, doors: []stubDoor{{name: "garage", open: true, since: time.Hour}, {name: "shed"}}},
+		{at: 80 * time.Minute, doors: []stubDoor{{name: "garage", since: 80 * time.Minute}, {name: "shed"}}},
+		{at: 3 * time.Hour, doors: []stubDoor{{name: "garage", since: 80 * time.Minute}, {name: "shed", open: true, since: 3 * time.Hour}}},
+		{at: 3*time.Hour + 45*time.Minute, doors: []stubDoor{{name: "garage", since: 80 * time.Minute}, {name: "shed", since: 3*time.Hour + 45*time.Minute}}},
+		{at: 23 * time.Hour, doors: []stubDoor{{name: "garage", open: true, since: 23 * time.Hour}, {name: "shed", since: 3*time.Hour + 45*time.Minute}}},
+		{at: 23*time.Hour + 59*time.Minute},
+		{at: 24 * time.Hour, want: "3 door openings, longest was garage at 1 hour. Still open: garage (1 hour)."},
+		{at: 25 * time.Hour},
+		// The next day starts from zero openings, though the garage closing
+		// counts towards it.
+		{at: 48 * time.Hour, doors: []stubDoor{{name: "garage", since: 47 * time.Hour}, {name: "shed", since: 3*time.Hour + 45*time.Minute}}, want: "0 door openings, longest was garage at 1 day. All doors are closed."},
+	}
+
+	for _, step := range steps {
+		now := pollStart.Add(step.at)
+		clock.Set(now)
+		for _, d := range step.doors {
+			porter.set(d.name, d.open, pollStart.Add(d.since))
+		}
+
+		before := len(rec.sent())
+		m.poll(context.Background())
+		m.maybeSendDigest(context.Background(), now)
+
+		var digests []string
+		for _, msg := range rec.sent()[before:] {
+			if strings.Contains(msg, summary) {
+				digests = append(digests, msg)
+			}
+		}
+		switch {
+		case step.want == "" && len(digests) > 0:
+			t.Errorf("at %v: sent %q before the digest was due", step.at, digests)
+		case step.want != "" && (len(digests) != 1 || !strings.HasSuffix(digests[0], summary+step.want)):
+			t.Errorf("at %v: sent %q, want a digest ending %q", step.at, digests, step.want)
+		}
+	}
+}
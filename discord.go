@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const discordMaxContentLength = 2000
+
+type DiscordNotifier struct {
+	WebhookURL string
+}
+
+func (d *DiscordNotifier) Send(ctx context.Context, msg string) error {
+	payload, err := json.Marshal(map[string]string{"content": truncate(msg, discordMaxContentLength)})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", d.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("building discord request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	// Discord's rate limit responses carry a Retry-After header, which
+	// postWithRetry waits out.
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	res, err := postWithRetry(ctx, httpClient, req, webhookRetryPolicy)
+	if err != nil {
+		return fmt.Errorf("sending to discord: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &httpStatusError{Service: "discord", Status: res.StatusCode}
+	}
+
+	return nil
+}
+
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max-1]) + "…"
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDiscordNotifier(t *testing.T) {
+	long := strings.Repeat("é", discordMaxContentLength+10)
+
+	tests := []struct {
+		name    string
+		msg     string
+		status  int
+		want    string
+		wantErr bool
+	}{
+		{"short", "Garage is open.", http.StatusNoContent, "Garage is open.", false},
+		{"exactly the limit", long[:2*discordMaxContentLength], http.StatusNoContent, long[:2*discordMaxContentLength], false},
+		{"truncated", long, http.StatusNoContent, long[:2*(discordMaxContentLength-1)] + "…", false},
+		{"rejected", "Garage is open.", http.StatusBadRequest, "Garage is open.", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			err := (&DiscordNotifier{WebhookURL: srv.URL}).Send(context.Background(), tt.msg)
+			var statusErr *httpStatusError
+			switch {
+			case tt.wantErr && !(errors.As(err, &statusErr) && statusErr.Status == tt.status):
+				t.Errorf("Send() error = %v, want HTTP %d", err, tt.status)
+			case !tt.wantErr && err != nil:
+				t.Errorf("Send() error = %v", err)
+			}
+			if got["content"] != tt.want {
+				t.Errorf("content is %d runes, want %d", utf8.RuneCountInString(got["content"]), utf8.RuneCountInString(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// DryRunNotifier prints what each wrapped notifier would have sent instead of
+// sending it.
+type DryRunNotifier struct {
+	Notifiers []Notifier
+}
+
+func (d *DryRunNotifier) Send(ctx context.Context, msg string) error {
+	for _, n := range d.Notifiers {
+		slog.Info("dry run notification", "channel", channelName(n), "recipients", strings.Join(recipientsOf(n), ","), "message", msg)
+	}
+	return nil
+}
+
+func channelName(n Notifier) string {
+	switch n := n.(type) {
+	case *SeverityNotifier:
+		return channelName(n.Notifier)
+	case *DedupNotifier:
+		return channelName(n.Notifier)
+	case *BreakerNotifier:
+		return channelName(n.Notifier)
+	case *AuditNotifier:
+		return channelName(n.Notifier)
+	case *ContactNotifier:
+		return channelName(n.Notifier)
+	case *QueueNotifier:
+		return channelName(n.Notifier)
+	case *TwilioNotifier:
+		if n.WhatsApp {
+			return "whatsapp"
+		}
+		return "sms"
+	case *TwilioVoiceNotifier:
+		return "voice"
+	case *EmailNotifier:
+		return "email"
+	case *SlackNotifier:
+		return "slack"
+	case *DiscordNotifier:
+		return "discord"
+	case *TelegramNotifier:
+		return "telegram"
+	case *WebhookNotifier:
+		return "webhook"
+	case *MQTTNotifier:
+		return "mqtt"
+	case *NtfyNotifier:
+		return "ntfy"
+	case *PagerDutyNotifier:
+		return "pagerduty"
+	case *MatrixNotifier:
+		return "matrix"
+	case *SignalNotifier:
+		return "signal"
+	case *PushoverNotifier:
+		return "pushover"
+	case *OpsgenieNotifier:
+		return "opsgenie"
+	default:
+		return fmt.Sprintf("%T", n)
+	}
+}
+
+func recipientsOf(n Notifier) []string {
+	switch n := n.(type) {
+	case *TwilioNotifier:
+		return n.recipients()
+	case *TwilioVoiceNotifier:
+		return n.Recipients
+	case *EmailNotifier:
+		return n.To
+	case *SlackNotifier:
+		return []string{n.WebhookURL}
+	case *DiscordNotifier:
+		return []string{n.WebhookURL}
+	case *TelegramNotifier:
+		return n.ChatIDs
+	case *WebhookNotifier:
+		return []string{n.URL}
+	case *MQTTNotifier:
+		return []string{n.Topic}
+	case *NtfyNotifier:
+		return []string{n.URL}
+	case *PagerDutyNotifier:
+		return []string{"pagerduty"}
+	case *MatrixNotifier:
+		return []string{n.RoomID}
+	case *SignalNotifier:
+		return n.Recipients
+	case *PushoverNotifier:
+		return []string{n.User}
+	case *OpsgenieNotifier:
+		return []string{"opsgenie"}
+	case *MultiNotifier:
+		return recipientsOfAll(n.Notifiers)
+	case *FallbackNotifier:
+		return recipientsOfAll(n.Notifiers)
+	case *DryRunNotifier:
+		return recipientsOfAll(n.Notifiers)
+	case *SeverityNotifier:
+		return recipientsOf(n.Notifier)
+	case *DedupNotifier:
+		return recipientsOf(n.Notifier)
+	case *BreakerNotifier:
+		return recipientsOf(n.Notifier)
+	case *AuditNotifier:
+		return recipientsOf(n.Notifier)
+	case *ContactNotifier:
+		return recipientsOf(n.Notifier)
+	case *QueueNotifier:
+		return recipientsOf(n.Notifier)
+	default:
+		return nil
+	}
+}
+
+func recipientsOfAll(notifiers []Notifier) []string {
+	var all []string
+	for _, n := range notifiers {
+		all = append(all, recipientsOf(n)...)
+	}
+	return all
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSMTP is a minimal SMTP server that records each session, rejecting
+// recipients in reject.
+type fakeSMTP struct {
+	ln     net.Listener
+	reject map[string]bool
+
+	mu    sync.Mutex
+	auth  string
+	from  string
+	rcpts []string
+	data  string
+}
+
+func newFakeSMTP(t *testing.T, reject ...string) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, reject: make(map[string]bool)}
+	for _, r := range reject {
+		s.reject[r] = true
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) port() int { return s.ln.Addr().(*net.TCPAddr).Port }
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd, arg, _ := strings.Cut(line, " ")
+
+		s.mu.Lock()
+		switch strings.ToUpper(cmd) {
+		case "EHLO":
+			reply("250-fake")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			s.auth = arg
+			reply("235 ok")
+		case "MAIL":
+			s.from = arg
+			reply("250 ok")
+		case "RCPT":
+			rcpt := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			if s.reject[rcpt] {
+				reply("550 no such user")
+				break
+			}
+			s.rcpts = append(s.rcpts, rcpt)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.data = b.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			s.mu.Unlock()
+			return
+		default:
+			reply("502 not implemented")
+		}
+		s.mu.Unlock()
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	tests := []struct {
+		name      string
+		to        []string
+		reject    []string
+		username  string
+		wantErr   string
+		wantRcpts int
+	}{
+		{"one recipient", []string{"a@example.com"}, nil, "", "", 1},
+		{"several recipients with auth", []string{"a@example.com", "b@example.com"}, nil, "porter", "", 2},
+		{"rejected recipient", []string{"a@example.com", "nobody@example.com"}, []string{"nobody@example.com"}, "", "RCPT TO nobody@example.com", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeSMTP(t, tt.reject...)
+			e := &EmailNotifier{Host: "127.0.0.1", Port: srv.port(), Username: tt.username, Password: "secret", From: "porter@example.com", To: tt.to}
+
+			err := e.Send(context.Background(), "Garage has been open for 30 minutes.")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Send() error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			srv.mu.Lock()
+			defer srv.mu.Unlock()
+			if srv.from != "FROM:<porter@example.com>" {
+				t.Errorf("MAIL %s, want FROM:<porter@example.com>", srv.from)
+			}
+			if len(srv.rcpts) != tt.wantRcpts {
+				t.Errorf("accepted recipients %q, want %d", srv.rcpts, tt.wantRcpts)
+			}
+			if tt.username != "" {
+				want := "PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00porter\x00secret"))
+				if srv.auth != want {
+					t.Errorf("AUTH %s, want %s", srv.auth, want)
+				}
+			}
+			if tt.wantErr == "" {
+				for _, header := range []string{"From: porter@example.com", "To: " + strings.Join(tt.to, ", "), "Subject: Porter notice"} {
+					if !strings.Contains(srv.data, header+"\r\n") {
+						t.Errorf("message lacks %q:\n%s", header, srv.data)
+					}
+				}
+				if !strings.HasSuffix(srv.data, "\r\n\r\nGarage has been open for 30 minutes.\r\n") {
+					t.Errorf("message body:\n%s", srv.data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// EventRecord is one entry in the door event history.
+type EventRecord struct {
+	Time time.Time `json:"ts"`
+	Kind string    `json:"kind"` // "open", "close" or "notify"
+	Door string    `json:"door,omitempty"`
+
+	// Type is the notification's event name, for notify records.
+	Type    string `json:"type,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// EventFilter selects records from an EventStore. Zero fields match
+// everything.
+type EventFilter struct {
+	Door  string
+	Kind  string
+	Since time.Time
+	Until time.Time
+}
+
+func (f EventFilter) matches(r EventRecord) bool {
+	switch {
+	case f.Door != "" && r.Door != f.Door:
+		return false
+	case f.Kind != "" && r.Kind != f.Kind:
+		return false
+	case !f.Since.IsZero() && r.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !r.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// EventStore keeps a queryable history of door events and notifications.
+type EventStore interface {
+	Record(EventRecord) error
+	Query(EventFilter) ([]EventRecord, error)
+}
+
+// FileEventStore is an EventStore backed by a file of JSON lines.
+type FileEventStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileEventStore(path string) *FileEventStore {
+	return &FileEventStore{path: path}
+}
+
+func (s *FileEventStore) Record(r EventRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening event store: %w", err)
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("writing event store: %w", err)
+	}
+	return f.Close()
+}
+
+func (s *FileEventStore) Query(filter EventFilter) ([]EventRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("opening event store: %w", err)
+	}
+	defer f.Close()
+
+	var records []EventRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var r EventRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// Skip a line torn by a crash mid-write rather than losing
+			// the rest of the history.
+			continue
+		}
+		if filter.matches(r) {
+			records = append(records, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading event store: %w", err)
+	}
+	return records, nil
+}
+
+// recordEvent adds r to the event store, if there is one.
+func (a *App) recordEvent(r EventRecord) {
+	if a.events == nil {
+		return
+	}
+	if err := a.events.Record(r); err != nil {
+		slog.Error("failed to record event", "kind", r.Kind, "door", r.Door, "error", err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEventStoreQuery(t *testing.T) {
+	base := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	records := []EventRecord{
+		{Time: base, Kind: "open", Door: "garage"},
+		{Time: base.Add(time.Minute), Kind: "close", Door: "garage"},
+		{Time: base.Add(2 * time.Minute), Kind: "open", Door: "front"},
+		{Time: base.Add(20 * time.Minute), Kind: "notify", Door: "front", Type: "open", Message: "front has been open for 18 minutes"},
+		{Time: base.Add(time.Hour), Kind: "close", Door: "front"},
+	}
+
+	tests := []struct {
+		name   string
+		filter EventFilter
+		want   []int // indexes into records
+	}{
+		{"everything", EventFilter{}, []int{0, 1, 2, 3, 4}},
+		{"door", EventFilter{Door: "front"}, []int{2, 3, 4}},
+		{"kind", EventFilter{Kind: "open"}, []int{0, 2}},
+		{"since is inclusive", EventFilter{Since: base.Add(time.Minute)}, []int{1, 2, 3, 4}},
+		{"until is exclusive", EventFilter{Until: base.Add(20 * time.Minute)}, []int{0, 1, 2}},
+		{"door and range", EventFilter{Door: "front", Since: base.Add(10 * time.Minute), Until: base.Add(2 * time.Hour)}, []int{3, 4}},
+		{"no match", EventFilter{Door: "shed"}, nil},
+	}
+
+	store := NewFileEventStore(filepath.Join(t.TempDir(), "events.jsonl"))
+	for _, r := range records {
+		if err := store.Record(r); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Query(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d records, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, idx := range tt.want {
+				if want := records[idx]; !got[i].Time.Equal(want.Time) || got[i].Kind != want.Kind || got[i].Door != want.Door || got[i].Type != want.Type || got[i].Message != want.Message {
+					t.Errorf("record %d = %+v, want %+v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestEventStoreQueryMissingFile(t *testing.T) {
+	store := NewFileEventStore(filepath.Join(t.TempDir(), "missing.jsonl"))
+	got, err := store.Query(EventFilter{})
+	if err != nil || len(got) != 0 {
+		t.Fatalf("Query = %v, %v; want no records and no error", got, err)
+	}
+}
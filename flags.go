@@ -0,0 +1,310 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+// options holds the values of the command line flags, including the Config
+// fields that are set from a flag directly.
+type options struct {
+	cfg Config
+
+	configFile string
+
+	accountSID      string
+	twilioAuthToken string
+	sender          string
+	senderList      string
+	defaultRegion   string
+	msgService      string
+	recipientsFile  string
+	rcptList        string
+	contactList     stringList
+	escalateList    string
+	whatsAppList    string
+	whatsAppSender  string
+	callList        string
+	callTime        int
+	criticalList    string
+	sendRetries     int
+	sendConcurrency int
+	splitLongSMS    bool
+	maxSMSPerDay    int
+	smsRate         float64
+	snapshotURL     string
+	doorSnapshots   string
+	twTimeout       int
+
+	smtpHost  string
+	smtpPort  int
+	smtpUser  string
+	smtpPass  string
+	emailFrom string
+	emailTo   string
+
+	slackWebhook       string
+	discordWebhook     string
+	tgToken            string
+	tgChatIDs          string
+	webhookURL         string
+	webhookBody        string
+	webhookContentType string
+
+	ntfyURL   string
+	ntfyToken string
+
+	opsgenieKey      string
+	opsgenieURL      string
+	pushoverToken    string
+	pushoverUser     string
+	pushoverRetry    int
+	pushoverExpire   int
+	signalURL        string
+	signalNumber     string
+	signalList       string
+	matrixHomeserver string
+	matrixRoom       string
+	matrixToken      string
+	pdRoutingKey     string
+
+	mqttBroker   string
+	mqttTopic    string
+	mqttClientID string
+	mqttUser     string
+	mqttPass     string
+	mqttQoS      int
+	mqttRetain   bool
+
+	testNotify       bool
+	dryRun           bool
+	channelSeverity  string
+	dedupWindow      int
+	breakerThreshold int
+	breakerCooldown  int
+	notifyMode       string
+
+	porterApiURIs    stringList
+	porterApiKeys    stringList
+	porterCACert     string
+	porterClientCert string
+	porterClientKey  string
+	porterAuth       string
+	porterInsecure   bool
+	porterUserAgent  string
+
+	openTime         int
+	digestTime       string
+	notifyOnStart    bool
+	notifyOnStop     bool
+	stopSummary      bool
+	startupGrace     int
+	closeDebounce    int
+	doorNames        string
+	offDayTime       int
+	offDayDoorThresh string
+	offDays          string
+	holidays         string
+	doorThresh       string
+	flapWindow       int
+	maxDailyOpen     int
+	missingAfter     int
+	maxAge           int
+	repeatMax        int
+	notifyTime       int
+	healthAddr       string
+	healthStale      int
+	inboundAddr      string
+	inboundURL       string
+	skipSigCheck     bool
+	apiAddr          string
+	apiKey           string
+	metricsAddr      string
+	msgOpen          string
+	msgClosed        string
+	msgStarting      string
+	msgStopping      string
+	msgError         string
+	msgRecover       string
+	disableMsgs      string
+	templateFile     string
+	langDir          string
+	timezone         string
+	queueFile        string
+	eventStore       string
+	auditLogPath     string
+	once             bool
+	pollTimeout      int
+	pollTime         int
+
+	quietStart string
+	quietEnd   string
+	quietMode  string
+
+	logLevel       string
+	logFormat      string
+	useSyslog      bool
+	syslogFacility string
+	syslogTag      string
+	showStatus     bool
+	showVersion    bool
+}
+
+// registerFlags defines the reporter's flags on fs and returns where their
+// values are stored.
+func registerFlags(fs *flag.FlagSet) *options {
+	o := &options{cfg: Config{DigestAt: -1, TimeLocation: time.Local}}
+	o.porterApiURIs = stringList{values: []string{"http://localhost:8080"}}
+	o.porterApiKeys = stringList{values: []string{"default"}}
+
+	fs.StringVar(&o.configFile, "config", "", "Read options from this JSON or YAML file; command line flags take precedence")
+
+	fs.StringVar(&o.accountSID, "twsid", "", "Twilio account SID")
+	fs.StringVar(&o.twilioAuthToken, "twtoken", "", "Twilio authentication token")
+	fs.StringVar(&o.sender, "twsender", "", "Your Twilio sender number")
+	fs.StringVar(&o.senderList, "twsenders", "", "Several Twilio sender numbers to rotate between, in format '+18005550100,+18005550101,...'")
+	fs.StringVar(&o.defaultRegion, "defaultregion", "", "Region such as 'US' or 'GB' used to complete phone numbers given without a country code")
+	fs.StringVar(&o.msgService, "twmsgservice", "", "Send through this Twilio Messaging Service SID instead of -twsender")
+	fs.StringVar(&o.recipientsFile, "recipientsfile", "", "Read additional recipients from this file, one per line; reloaded on SIGHUP")
+	fs.StringVar(&o.rcptList, "recipients", "", "Recipients list in format '+18005550199,+18008675309,...'")
+	fs.Var(&o.contactList, "contact", "Recipient on their preferred channel, in format 'sms:+18005550199', 'email:a@example.com' or 'telegram:12345', optionally followed by ';quiet=22:00-07:00' and ';lang=es'; may be repeated")
+	fs.StringVar(&o.escalateList, "escalate", "", "Additional recipients in format '+18005550199,...' to text once -escalateafter repeats go unanswered")
+	fs.StringVar(&o.whatsAppList, "whatsapprecipients", "", "Recipients in format '+18005550199,...' to message on WhatsApp through Twilio")
+	fs.StringVar(&o.whatsAppSender, "whatsappsender", "", "WhatsApp-enabled Twilio number to send from (default the first -twsender)")
+	fs.StringVar(&o.callList, "callrecipients", "", "Recipients in format '+18005550199,...' to phone once a door has been open for -callthreshold")
+	fs.IntVar(&o.callTime, "callthreshold", 0, "Place a voice call to -callrecipients after a door has been open this many minutes (0 to disable)")
+	fs.StringVar(&o.criticalList, "criticalrecipients", "", "Additional recipients in format '+18005550199,...' to text for critical messages, such as losing contact with the controller")
+	fs.IntVar(&o.cfg.EscalateAfter, "escalateafter", 0, "Include -escalate recipients after this many repeat notifications for the same open event (0 to disable)")
+	fs.IntVar(&o.sendRetries, "sendretries", 3, "Retry failed SMS sends this many times")
+	fs.IntVar(&o.sendConcurrency, "sendconcurrency", 4, "Send to at most this many SMS recipients at once")
+	fs.BoolVar(&o.splitLongSMS, "splitlongsms", false, "Send messages longer than one SMS segment as several numbered texts")
+	fs.IntVar(&o.maxSMSPerDay, "maxsmsperday", 0, "Send at most this many SMS per day, counted from midnight; further texts are dropped (0 for no limit)")
+	fs.Float64Var(&o.smsRate, "smsrate", 0, "Send at most this many SMS per second across all recipients (0 for no limit; Twilio long codes allow about 1)")
+	fs.StringVar(&o.snapshotURL, "snapshoturl", "", "Attach the picture at this URL to door open texts as MMS")
+	fs.StringVar(&o.doorSnapshots, "doorsnapshots", "", "Per-door snapshot URLs, overriding -snapshoturl, in format 'garage=https://...,shed=https://...'")
+	fs.IntVar(&o.twTimeout, "twtimeout", 30, "Timeout in seconds for each Twilio API request")
+
+	fs.StringVar(&o.smtpHost, "smtphost", "", "SMTP server host for email notifications")
+	fs.IntVar(&o.smtpPort, "smtpport", 587, "SMTP server port")
+	fs.StringVar(&o.smtpUser, "smtpuser", "", "SMTP username")
+	fs.StringVar(&o.smtpPass, "smtppass", "", "SMTP password")
+	fs.StringVar(&o.emailFrom, "emailfrom", "", "Email sender address")
+	fs.StringVar(&o.emailTo, "emailto", "", "Email recipients list in format 'a@example.com,b@example.com,...'")
+
+	fs.StringVar(&o.slackWebhook, "slackwebhook", "", "Slack incoming webhook URL")
+	fs.StringVar(&o.discordWebhook, "discordwebhook", "", "Discord webhook URL")
+	fs.StringVar(&o.tgToken, "tgtoken", "", "Telegram bot token")
+	fs.StringVar(&o.tgChatIDs, "tgchatids", "", "Telegram chat IDs in format '12345,67890,...'")
+	fs.StringVar(&o.webhookURL, "webhookurl", "", "Generic webhook URL")
+	fs.StringVar(&o.webhookBody, "webhookbody", "", "Webhook body template (fields: .Message, .DoorName, .Event, .Duration)")
+	fs.StringVar(&o.webhookContentType, "webhookcontenttype", "application/json", "Webhook Content-Type header")
+
+	fs.StringVar(&o.ntfyURL, "ntfyurl", "", "ntfy topic URL, e.g. 'https://ntfy.sh/my-garage'")
+	fs.StringVar(&o.ntfyToken, "ntfytoken", "", "ntfy access token for protected topics")
+
+	fs.StringVar(&o.opsgenieKey, "opsgeniekey", "", "Opsgenie API integration key; doors left open create an alert that closes when they close")
+	fs.StringVar(&o.opsgenieURL, "opsgenieurl", opsgenieAPIURL, "Opsgenie API URL, e.g. https://api.eu.opsgenie.com for the EU instance")
+	fs.StringVar(&o.pushoverToken, "pushovertoken", "", "Pushover application API token")
+	fs.StringVar(&o.pushoverUser, "pushoveruser", "", "Pushover user or group key to notify")
+	fs.IntVar(&o.pushoverRetry, "pushoverretry", 60, "Seconds between Pushover repeats of emergency messages until acknowledged (minimum 30)")
+	fs.IntVar(&o.pushoverExpire, "pushoverexpire", 3600, "Seconds to keep repeating Pushover emergency messages (maximum 10800)")
+	fs.StringVar(&o.signalURL, "signalurl", "", "signal-cli REST API URL to send Signal messages through, e.g. http://localhost:8080")
+	fs.StringVar(&o.signalNumber, "signalnumber", "", "Number registered with signal-cli to send from")
+	fs.StringVar(&o.signalList, "signalrecipients", "", "Recipients in format '+18005550199,...' to message on Signal")
+	fs.StringVar(&o.matrixHomeserver, "matrixhomeserver", "", "Matrix homeserver URL, e.g. https://matrix.example.org")
+	fs.StringVar(&o.matrixRoom, "matrixroom", "", "Matrix room ID to post to, e.g. '!abc123:example.org'")
+	fs.StringVar(&o.matrixToken, "matrixtoken", "", "Matrix access token of the account to post as")
+	fs.StringVar(&o.pdRoutingKey, "pdroutingkey", "", "PagerDuty Events API v2 routing key; doors left open trigger an incident that resolves when they close")
+
+	fs.StringVar(&o.mqttBroker, "mqttbroker", "", "Publish events to this MQTT broker, e.g. 'tcp://localhost:1883' or 'ssl://broker:8883'")
+	fs.StringVar(&o.mqttTopic, "mqtttopic", "porter/events", "MQTT topic to publish events to")
+	fs.StringVar(&o.mqttClientID, "mqttclientid", "", "MQTT client ID (default porter-reporter- and a random suffix)")
+	fs.StringVar(&o.mqttUser, "mqttuser", "", "MQTT username")
+	fs.StringVar(&o.mqttPass, "mqttpass", "", "MQTT password")
+	fs.IntVar(&o.mqttQoS, "mqttqos", 0, "MQTT QoS level, 0 or 1")
+	fs.BoolVar(&o.mqttRetain, "mqttretain", false, "Publish MQTT events as retained messages")
+
+	fs.BoolVar(&o.testNotify, "testnotify", false, "Send a test notification through every configured channel, report the results and exit")
+	fs.BoolVar(&o.dryRun, "dryrun", false, "Print notifications to stdout instead of sending them")
+	fs.StringVar(&o.channelSeverity, "channelseverity", "", "Minimum severity (info, warning or critical) each channel receives, in format 'slack=info,sms=warning,...'")
+	fs.IntVar(&o.dedupWindow, "dedupwindow", 60, "Send the same event through each channel at most once within this many seconds (0 to disable)")
+	fs.IntVar(&o.breakerThreshold, "breakerthreshold", 5, "Stop trying a channel after this many consecutive failures (0 to disable)")
+	fs.IntVar(&o.breakerCooldown, "breakercooldown", 300, "Seconds to wait before retrying a channel stopped by -breakerthreshold")
+	fs.StringVar(&o.notifyMode, "notifymode", "fanout", "Deliver to every channel ('fanout') or to the first that succeeds ('fallback')")
+
+	fs.Var(&o.porterApiURIs, "papi", "Porter API server URI; repeat as 'label=URI' to monitor several controllers")
+	fs.Var(&o.porterApiKeys, "pkey", "Porter API key; repeat once per -papi when controllers use different keys")
+	fs.StringVar(&o.porterCACert, "pcacert", "", "PEM file of CA certificates to trust for the Porter API")
+	fs.StringVar(&o.porterClientCert, "pclientcert", "", "PEM client certificate to present to the Porter API, with -pclientkey")
+	fs.StringVar(&o.porterClientKey, "pclientkey", "", "PEM private key for -pclientcert")
+	fs.StringVar(&o.porterAuth, "pauth", "apikey", "How -pkey is sent to the Porter API: 'apikey', or 'bearer' for an Authorization: Bearer header")
+	fs.BoolVar(&o.porterInsecure, "pinsecure", false, "Don't verify the Porter API's TLS certificate (for local testing only)")
+	fs.StringVar(&o.porterUserAgent, "puseragent", "", "User-Agent for requests to the Porter API (default porter-reporter/ and the version)")
+
+	fs.IntVar(&o.openTime, "openthresh", 30, "Send notification after this many minutes")
+	fs.StringVar(&o.digestTime, "digestat", "", "Send a daily summary at this time in format 'HH:MM'")
+	fs.BoolVar(&o.cfg.BatchNotify, "batchnotify", false, "Combine open notifications for several doors in the same poll into one message")
+	fs.BoolVar(&o.notifyOnStart, "notifyonstart", true, "Send a notification when the monitor starts")
+	fs.BoolVar(&o.notifyOnStop, "notifyonstop", true, "Send a notification when the monitor stops")
+	fs.BoolVar(&o.stopSummary, "stopsummary", false, "Include a summary of the run (uptime, polls, notifications sent) in the stop notification")
+	fs.BoolVar(&o.cfg.NotifyOnOpen, "notifyonopen", false, "Also send a notification as soon as a door opens")
+	fs.IntVar(&o.startupGrace, "startupgrace", 0, "Don't alert on doors already open at startup until this many minutes after starting")
+	fs.IntVar(&o.closeDebounce, "closedebounce", 0, "Only treat a door as closed once it has stayed closed for this many seconds")
+	fs.StringVar(&o.doorNames, "doornames", "", "Names to show in messages for doors, in format 'gpio17=Garage Door,gpio18=Shed'")
+	fs.IntVar(&o.offDayTime, "offdaythresh", 0, "Send notification after this many minutes on -offdays and -holidays instead (0 to use -openthresh)")
+	fs.StringVar(&o.offDayDoorThresh, "offdaydoorthresh", "", "Per-door open thresholds in minutes for -offdays and -holidays, in format 'garage=60,shed=240,...'")
+	fs.StringVar(&o.offDays, "offdays", "sat,sun", "Days of the week -offdaythresh applies to, in format 'sat,sun'")
+	fs.StringVar(&o.holidays, "holidays", "", "Dates -offdaythresh also applies to, in format '2025-12-25,2026-01-01,...'")
+	fs.StringVar(&o.doorThresh, "doorthresh", "", "Per-door open thresholds in minutes, overriding -openthresh, in format 'garage=15,shed=120,...'")
+	fs.IntVar(&o.cfg.FlapCount, "flapcount", 0, "Treat a door that changes state more than this many times within -flapwindow as flapping, and send one notice instead of an alert per change (0 to disable)")
+	fs.IntVar(&o.flapWindow, "flapwindow", 5, "Window in minutes for -flapcount")
+	fs.IntVar(&o.maxDailyOpen, "maxdailyopen", 0, "Send a notice when a door has been open for this many minutes in total since midnight (0 to disable)")
+	fs.IntVar(&o.missingAfter, "missingafter", 0, "Send a notice when a door the controller used to list has been missing for this many minutes (0 to disable)")
+	fs.IntVar(&o.maxAge, "maxstateage", 720, "Ignore open doors whose last state change is older than this many hours, as the controller clock is likely wrong (0 to disable)")
+	fs.BoolVar(&o.cfg.ClockAlert, "clockalert", false, "Send an alert when a door reports a state change time in the future or older than -maxstateage")
+	fs.BoolVar(&o.cfg.RepeatBackoff, "repeatbackoff", false, "Double the repeat interval after each repeat notification, up to -repeatmax")
+	fs.IntVar(&o.repeatMax, "repeatmax", 240, "Longest repeat interval in minutes with -repeatbackoff")
+	fs.IntVar(&o.cfg.MaxRepeats, "maxrepeats", 0, "Stop repeating after this many repeat notifications until the door closes and reopens (0 for no limit)")
+	fs.IntVar(&o.notifyTime, "repeatthresh", 60, "Send repeat notifications at this interval (0 to send only one)")
+	fs.StringVar(&o.healthAddr, "healthaddr", "", "Serve a /healthz endpoint on this address, e.g. ':8081'")
+	fs.IntVar(&o.healthStale, "healthstale", 60, "Report unhealthy when the last successful poll is older than this many seconds")
+	fs.StringVar(&o.inboundAddr, "inboundaddr", "", "Accept Twilio incoming message webhooks at /sms on this address, e.g. ':8082'")
+	fs.StringVar(&o.inboundURL, "inboundurl", "", "Public URL Twilio calls for incoming messages, used to validate signatures (default reconstructed from the request)")
+	fs.BoolVar(&o.skipSigCheck, "skipsigcheck", false, "Don't validate X-Twilio-Signature on incoming messages (local testing only)")
+	fs.StringVar(&o.apiAddr, "apiaddr", "", "Serve the JSON door status API on this address, e.g. ':8083'")
+	fs.StringVar(&o.apiKey, "apikey", "", "Require this key in the X-API-Key header for the JSON API")
+	fs.StringVar(&o.metricsAddr, "metricsaddr", "", "Serve Prometheus metrics at /metrics on this address, e.g. ':9090'")
+	fs.StringVar(&o.msgOpen, "msgopen", "", "Template for door open notifications (fields: .Time, .DoorName, .Duration)")
+	fs.StringVar(&o.msgClosed, "msgclosed", "", "Template for door closed notifications (fields: .Time, .DoorName)")
+	fs.StringVar(&o.msgStarting, "msgstarting", "", "Template for the monitor started notification (fields: .Time)")
+	fs.StringVar(&o.msgStopping, "msgstopping", "", "Template for the monitor stopping notification (fields: .Time, .Summary)")
+	fs.StringVar(&o.msgError, "msgerror", "", "Template for the controller unreachable notification (fields: .Time, .DoorName)")
+	fs.StringVar(&o.msgRecover, "msgrecover", "", "Template for the controller back online notification (fields: .Time, .DoorName)")
+	fs.StringVar(&o.disableMsgs, "disablemsgs", "", "Never send these messages, in format 'error,recover,...'")
+	fs.StringVar(&o.templateFile, "templatefile", "", "JSON file mapping message names ('open', 'closed') to templates")
+	fs.StringVar(&o.langDir, "langdir", "", "Directory of template files named by language, e.g. 'es.json', for contacts with a lang setting")
+	fs.StringVar(&o.cfg.TimeFormat, "timeformat", "Mon Jan 2 '06 3:04 PM", "Go time layout for timestamps in messages")
+	fs.StringVar(&o.timezone, "timezone", "", "IANA time zone for timestamps in messages, e.g. 'America/New_York' (default host time zone)")
+	fs.StringVar(&o.cfg.StateFile, "statefile", "", "Persist door state to this JSON file across restarts")
+	fs.StringVar(&o.queueFile, "queuefile", "", "Keep notifications in this file until they have been delivered, retrying them after a failure or restart")
+	fs.StringVar(&o.eventStore, "eventstore", "", "Record door events and notifications to this file, as JSON lines")
+	fs.StringVar(&o.auditLogPath, "auditlog", "", "Append a JSON line per notification, with the result for each channel, to this file; reopened on SIGHUP")
+	fs.BoolVar(&o.once, "once", false, "Poll once, send any due notifications and exit (for running from cron; requires -statefile)")
+	fs.IntVar(&o.pollTimeout, "ptimeout", 30, "Timeout in seconds for each Porter API request (0 for no limit)")
+	fs.IntVar(&o.pollTime, "pollinterval", 5, "Poll the Porter API every this many seconds (minimum 1)")
+	fs.Float64Var(&o.cfg.PollJitter, "polljitter", 0, "Vary each poll interval randomly by up to this fraction either way, e.g. 0.1 for 10%")
+
+	fs.StringVar(&o.quietStart, "quietstart", "", "Start of quiet hours in format 'HH:MM', during which door notifications are held back")
+	fs.StringVar(&o.quietEnd, "quietend", "", "End of quiet hours in format 'HH:MM'")
+	fs.StringVar(&o.quietMode, "quietmode", "drop", "What to do with notifications during quiet hours: 'drop' or 'defer' until quiet hours end")
+
+	fs.StringVar(&o.logLevel, "loglevel", "info", "Log level: debug, info, warn or error")
+	fs.StringVar(&o.logFormat, "logformat", "text", "Log format: text or json")
+	fs.BoolVar(&o.useSyslog, "syslog", false, "Send logs to the local syslog instead of stderr")
+	fs.StringVar(&o.syslogFacility, "syslogfacility", "daemon", "Syslog facility, e.g. daemon or local0")
+	fs.StringVar(&o.syslogTag, "syslogtag", "porter-reporter", "Syslog tag")
+	fs.BoolVar(&o.showStatus, "status", false, "Show a live summary of every door when running in a terminal")
+	fs.BoolVar(&o.showVersion, "version", false, "Print the version and exit")
+
+	return o
+}
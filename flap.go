@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// noteTransition records a state change for a door and, with -flapcount set,
+// sends a single notice when the door starts changing too often.
+func (m *monitor) noteTransition(ctx context.Context, doorName string, w *DoorWatch) {
+	cfg := &m.app.cfg
+	if cfg.FlapCount <= 0 {
+		return
+	}
+
+	now := m.app.clock.Now()
+	w.transitions = append(recentTransitions(w.transitions, now.Add(-cfg.FlapWindow)), now)
+	if !w.flapping && len(w.transitions) > cfg.FlapCount {
+		w.flapping = true
+		slog.Warn("door is flapping", "door", doorName, "changes", len(w.transitions), "window", cfg.FlapWindow)
+		m.app.notify(ctx, MsgFlapping, doorName)
+	}
+}
+
+// isFlapping reports whether per-change alerts for a door are being held back.
+// A door stops flapping once it has gone a whole window without changing.
+func (m *monitor) isFlapping(doorName string, w *DoorWatch) bool {
+	if !w.flapping {
+		return false
+	}
+
+	w.transitions = recentTransitions(w.transitions, m.app.clock.Now().Add(-m.app.cfg.FlapWindow))
+	if len(w.transitions) == 0 {
+		w.flapping = false
+		slog.Info("door stopped flapping", "door", doorName)
+		return false
+	}
+	return true
+}
+
+func recentTransitions(ts []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(ts) && ts[i].Before(since) {
+		i++
+	}
+	return ts[i:]
+}
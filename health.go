@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// pollHealth records the outcome of Porter polls for the health endpoint.
+type pollHealth struct {
+	mu          sync.Mutex
+	lastPoll    time.Time
+	lastSuccess time.Time
+	lastErr     error
+	staleAfter  time.Duration
+}
+
+type healthResponse struct {
+	Status      string    `json:"status"`
+	LastPoll    time.Time `json:"last_poll,omitempty"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+var health = &pollHealth{}
+
+func (h *pollHealth) record(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastPoll = time.Now()
+	h.lastErr = err
+	if err == nil {
+		h.lastSuccess = h.lastPoll
+	}
+}
+
+func (h *pollHealth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	res := healthResponse{
+		Status:      "ok",
+		LastPoll:    h.lastPoll,
+		LastSuccess: h.lastSuccess,
+	}
+	if h.lastErr != nil {
+		res.LastError = h.lastErr.Error()
+	}
+	healthy := !h.lastSuccess.IsZero() && time.Since(h.lastSuccess) <= h.staleAfter
+	h.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !healthy {
+		res.Status = "stale"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// serveHTTP starts an HTTP server on addr in the background.
+func serveHTTP(addr string, handler http.Handler) *http.Server {
+	srv := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("HTTP server failed", "addr", addr, "error", err)
+		}
+	}()
+	return srv
+}
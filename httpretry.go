@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryPolicy controls how postWithRetry retries a request.
+type retryPolicy struct {
+	// Attempts is the most times the request is sent, including the first.
+	Attempts int
+
+	// MaxWait is the longest Retry-After that is waited out; a server asking
+	// for longer gets its response returned as is.
+	MaxWait time.Duration
+}
+
+var webhookRetryPolicy = retryPolicy{Attempts: 3, MaxWait: 30 * time.Second}
+
+// postWithRetry sends req, whatever its method, retrying network errors, 429
+// and 5xx responses with backoff. A Retry-After header takes the place of the
+// backoff. The last response is returned whatever its status, and the caller
+// must close its body. Requests whose body can't be replayed (see
+// http.Request.GetBody) are only sent once.
+func postWithRetry(ctx context.Context, client *http.Client, req *http.Request, policy retryPolicy) (*http.Response, error) {
+	req = req.WithContext(ctx)
+	attempts := policy.Attempts
+	if attempts < 1 || (req.Body != nil && req.GetBody == nil) {
+		attempts = 1
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		last := attempt+1 >= attempts
+		res, err := client.Do(req)
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if last || ctx.Err() != nil {
+				return nil, err
+			}
+			wait = backoff(attempt)
+
+		case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+			if last {
+				return res, nil
+			}
+			wait = retryAfter(res.Header.Get("Retry-After"), time.Now())
+			if wait > policy.MaxWait {
+				return res, nil
+			}
+			if wait <= 0 {
+				wait = backoff(attempt)
+			}
+			io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+			res.Body.Close()
+
+		default:
+			return res, nil
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			if err != nil {
+				return nil, err
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header, given either in seconds or as an
+// HTTP date. It returns 0 if the header is absent or invalid.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// httpStatusError is a non-2xx response from a channel's API.
+type httpStatusError struct {
+	Service string
+	Status  int
+	Detail  string
+}
+
+func (e *httpStatusError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("%s returned HTTP %d", e.Service, e.Status)
+	}
+	return fmt.Sprintf("%s returned HTTP %d: %s", e.Service, e.Status, e.Detail)
+}
+
+func (e *httpStatusError) httpStatus() int { return e.Status }
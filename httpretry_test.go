@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPostWithRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		attempts   int
+		statuses   []int // successive responses; the last repeats
+		retryAfter string
+		body       io.Reader
+		wantStatus int
+		wantCalls  int
+	}{
+		{"success", 3, []int{200}, "", strings.NewReader("x"), 200, 1},
+		{"server error then success", 3, []int{503, 200}, "1", strings.NewReader("x"), 200, 2},
+		{"rate limited then success", 3, []int{429, 200}, "1", strings.NewReader("x"), 200, 2},
+		{"gives up after the last attempt", 2, []int{500}, "1", strings.NewReader("x"), 500, 2},
+		{"client error isn't retried", 3, []int{404}, "", strings.NewReader("x"), 404, 1},
+		{"too long a Retry-After isn't waited out", 3, []int{429, 200}, "3600", strings.NewReader("x"), 429, 1},
+		{"unreplayable body is sent once", 3, []int{503, 200}, "1", io.MultiReader(strings.NewReader("x")), 503, 1},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if b, _ := io.ReadAll(r.Body); string(b) != "x" {
+					t.Errorf("request body = %q, want %q", b, "x")
+				}
+				i := int(calls.Add(1)) - 1
+				if i >= len(tt.statuses) {
+					i = len(tt.statuses) - 1
+				}
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statuses[i])
+			}))
+			defer srv.Close()
+
+			req, err := http.NewRequest("POST", srv.URL, tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := postWithRetry(context.Background(), srv.Client(), req, retryPolicy{Attempts: tt.attempts, MaxWait: time.Minute})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if got := int(calls.Load()); got != tt.wantCalls {
+				t.Errorf("made %d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestPostWithRetryCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "20")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequest("POST", srv.URL, strings.NewReader("x"))
+	if _, err := postWithRetry(ctx, srv.Client(), req, webhookRetryPolicy); err == nil {
+		t.Error("postWithRetry succeeded after its context was done")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"0", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"soon", 0},
+	}
+
+	for _, tt := range tests {
+		if got := retryAfter(tt.header, now); got != tt.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
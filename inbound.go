@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hako/durafmt"
+)
+
+// muteSet tracks doors whose notifications have been silenced until they
+// next close, or until an expiry time if one was given.
+type muteSet struct {
+	mu      sync.Mutex
+	doors   map[string]time.Time // zero until the door closes
+	changed bool
+}
+
+func (m *muteSet) mute(doorName string) {
+	m.muteUntil(doorName, time.Time{})
+}
+
+func (m *muteSet) muteUntil(doorName string, until time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.doors[doorName] = until
+	m.changed = true
+}
+
+func (m *muteSet) unmute(doorName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.doors[doorName]; ok {
+		delete(m.doors, doorName)
+		m.changed = true
+	}
+}
+
+// isMuted reports whether doorName is muted at now, clearing the mute if it
+// has expired.
+func (m *muteSet) isMuted(doorName string, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	until, ok := m.doors[doorName]
+	if ok && !until.IsZero() && !now.Before(until) {
+		delete(m.doors, doorName)
+		m.changed = true
+		return false
+	}
+	return ok
+}
+
+// snapshot returns the current mutes for saving, and whether they changed
+// since the last snapshot.
+func (m *muteSet) snapshot() (map[string]time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	doors := make(map[string]time.Time, len(m.doors))
+	for name, until := range m.doors {
+		doors[name] = until
+	}
+	changed := m.changed
+	m.changed = false
+	return doors, changed
+}
+
+func (m *muteSet) restore(doors map[string]time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, until := range doors {
+		m.doors[name] = until
+	}
+}
+
+// doorView is the monitor's view of a door as of the latest poll.
+type doorView struct {
+	Name       string
+	Open       bool
+	LastChange time.Time
+}
+
+type doorSnapshot struct {
+	mu    sync.Mutex
+	doors []doorView
+}
+
+func (s *doorSnapshot) set(doors []doorView) {
+	sort.Slice(doors, func(i, j int) bool { return doors[i].Name < doors[j].Name })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.doors = doors
+}
+
+func (s *doorSnapshot) list() []doorView {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]doorView(nil), s.doors...)
+}
+
+func (s *doorSnapshot) find(name string) (doorView, bool) {
+	for _, d := range s.list() {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return doorView{}, false
+}
+
+// inboundHandler handles Twilio's incoming message webhook, letting
+// recipients text commands back to the monitor:
+//
+//	STOP <door>   mute notifications for a door until it closes
+//	STATUS        reply with the current state of every door
+type inboundHandler struct {
+	app *App
+
+	mu           sync.RWMutex
+	allowed      map[string]bool
+	authToken    string
+	publicURL    string
+	skipSigCheck bool
+}
+
+// setAllowed replaces the numbers that may send commands.
+func (h *inboundHandler) setAllowed(lists ...[]string) {
+	allowed := make(map[string]bool)
+	for _, list := range lists {
+		for _, number := range list {
+			allowed[number] = true
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.allowed = allowed
+}
+
+func (h *inboundHandler) isAllowed(number string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.allowed[number]
+}
+
+type twiml struct {
+	XMLName xml.Name `xml:"Response"`
+	Message string   `xml:"Message,omitempty"`
+}
+
+func (h *inboundHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if !h.skipSigCheck && !validTwilioRequest(r, h.authToken, h.publicURL) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	from := r.PostForm.Get("From")
+	if from == "" || r.PostForm.Get("MessageSid") == "" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if !h.isAllowed(from) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	reply := h.handleCommand(r.PostForm.Get("Body"))
+	slog.Info("inbound command", "from", from, "body", r.PostForm.Get("Body"))
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(twiml{Message: reply})
+}
+
+func (h *inboundHandler) handleCommand(body string) string {
+	fields := strings.Fields(body)
+	if len(fields) == 0 {
+		return "Commands: STOP <door>, STATUS"
+	}
+
+	switch strings.ToUpper(fields[0]) {
+	case "STOP", "MUTE":
+		if len(fields) < 2 {
+			return "Usage: STOP <door>"
+		}
+		name := strings.Join(fields[1:], " ")
+		door, ok := h.app.latestDoors.find(name)
+		if !ok {
+			return fmt.Sprintf("Unknown door %q.", name)
+		}
+		h.app.mutes.mute(door.Name)
+		return fmt.Sprintf("%s is muted until it closes.", door.Name)
+
+	case "STATUS":
+		doors := h.app.latestDoors.list()
+		if len(doors) == 0 {
+			return "No door states available yet."
+		}
+		lines := make([]string, 0, len(doors))
+		for _, d := range doors {
+			line := d.Name + ": closed"
+			if d.Open {
+				line = d.Name + ": open for " + durafmt.ParseShort(h.app.clock.Since(d.LastChange)).String()
+			}
+			if h.app.mutes.isMuted(d.Name, h.app.clock.Now()) {
+				line += " (muted)"
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n")
+
+	default:
+		return "Commands: STOP <door>, STATUS"
+	}
+}
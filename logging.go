@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// setupLogger installs the default slog logger according to -loglevel and
+// -logformat, writing to out. If syslogFacility is set, logs go to the local
+// syslog instead, falling back to out if it can't be reached.
+func setupLogger(out io.Writer, level, format, syslogFacility, syslogTag string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(out, opts)
+	case "json":
+		handler = slog.NewJSONHandler(out, opts)
+	default:
+		return fmt.Errorf("invalid log format %q, expected 'text' or 'json'", format)
+	}
+
+	if syslogFacility != "" {
+		sh, err := newSyslogHandler(syslogFacility, syslogTag, opts)
+		if err != nil {
+			slog.New(handler).Warn("logging to stderr instead of syslog", "error", err)
+		} else {
+			handler = sh
+		}
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
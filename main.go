@@ -1,20 +1,43 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
-	"github.com/hako/durafmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
 	"porter/client"
+	"strconv"
 	"strings"
-	"sync"
 	"syscall"
 	"time"
 )
 
+const sendTimeout = 60 * time.Second
+
+// observerTimeout bounds each door state report. Reports are made in line
+// with polling, so a hung observer mustn't hold up a poll, or shutdown, for
+// long.
+const observerTimeout = 10 * time.Second
+
+// Build metadata, set with e.g.
+// -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%d)".
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// maxPollBackoff caps how far the poll interval grows while the Porter API is
+// unreachable.
+const maxPollBackoff = 5 * time.Minute
+
 const (
 	MsgStateChangeOpen int = iota
 	MsgStateChangeClosed
@@ -22,191 +45,948 @@ const (
 	MsgMonitorStarting
 	MsgMonitorError
 	MsgMonitorRecover
+	MsgTest
+	MsgClockSkew
+	MsgDoorOpened
+	MsgBatchOpen
+	MsgDigest
+	MsgFlapping
+	MsgSMSBudget
+	MsgDoorMissing
+	MsgDailyOpenLimit
+	MsgManual
 )
 
 type DoorWatch struct {
 	lastStateChangeTS    time.Time
 	lastNotificationSent time.Time
+	repeats              int
+	openedTS             time.Time // open event already announced by -notifyonopen
+	calledTS             time.Time // open event already called about
+	closed               bool
+	lastClosed           time.Time
+	lastOpened           time.Time
+
+	// Recent state changes for flap detection; not persisted.
+	transitions []time.Time
+	flapping    bool
+
+	// When the controller last listed the door, and whether it has been
+	// reported missing since; not persisted.
+	lastSeen        time.Time
+	missingNotified bool
 }
 
-var porterClient *client.Client
+func main() {
+	o := registerFlags(flag.CommandLine)
+	registerSecretFlags(flag.CommandLine)
+	flag.Parse()
 
-var accountSID, twilioAuthToken, sender *string
-var recipients []string
+	if o.showVersion {
+		fmt.Printf("reporter %s (commit %s, built %s)\n", version, commit, buildDate)
+		os.Exit(0)
+	}
 
-var repeatNotificationThreshold, openNotificationThreshold time.Duration
+	cmdline := setFlags(flag.CommandLine)
+	if o.configFile != "" {
+		if err := applyConfigFile(flag.CommandLine, o.configFile); err != nil {
+			slog.Error("invalid configuration", "error", err)
+			os.Exit(1)
+		}
+	}
+	if err := applySecrets(flag.CommandLine, cmdline); err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	facility := ""
+	if o.useSyslog {
+		facility = o.syslogFacility
+	}
+	// The status view takes over the terminal, so it's only used with text
+	// logs that would otherwise go to it.
+	var status *statusView
+	var logOut io.Writer = os.Stderr
+	if o.showStatus && !o.once && isTerminal(os.Stdout) && facility == "" && strings.ToLower(o.logFormat) == "text" {
+		status = &statusView{out: os.Stdout}
+		logOut = status
+	}
+	if err := setupLogger(logOut, o.logLevel, o.logFormat, facility, o.syslogTag); err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	if o.showStatus && status == nil {
+		slog.Info("not showing status view; it needs a terminal and -logformat text")
+	}
 
-func main() {
-	accountSID = flag.String("twsid", "", "Twilio account SID")
-	twilioAuthToken = flag.String("twtoken", "", "Twilio authentication token")
-	sender = flag.String("twsender", "", "Your Twilio sender number")
-	rcptList := flag.String("recipients", "", "Recipients list in format '+18005550199,+18008675309,...'")
+	if err := checkNonNegativeFlags(flag.CommandLine); err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	app, err := buildApp(o)
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
 
-	porterApiURI := flag.String("papi", "http://localhost:8080", "Porter API server URI")
-	porterApiKey := flag.String("pkey", "default", "Porter API key")
+	switch {
+	case o.testNotify:
+		os.Exit(app.sendTestNotification(app.channels))
+	case o.once:
+		if err := app.pollOnce(context.Background()); err != nil {
+			slog.Error("poll failed", "error", err)
+			os.Exit(1)
+		}
+	default:
+		runDaemon(app, o, status)
+	}
+}
 
-	openTime := flag.Int("openthresh", 30, "Send notification after this many minutes")
-	notifyTime := flag.Int("repeatthresh", 60, "Send repeat notifications at this interval (0 to send only one)")
+// buildApp checks the options and sets up the App and its notifiers from
+// them.
+func buildApp(o *options) (*App, error) {
+	cfg := o.cfg
+	// Everything that keeps time shares the App's clock.
+	var clock Clock = realClock{}
+
+	switch {
+	case o.pollTime < 1:
+		return nil, errors.New("-pollinterval must be at least 1")
+	case cfg.PollJitter >= 1:
+		return nil, errors.New("-polljitter must be less than 1")
+	case o.once && cfg.StateFile == "":
+		return nil, errors.New("-once requires -statefile")
+	case o.inboundAddr != "" && o.twilioAuthToken == "" && !o.skipSigCheck:
+		// Without a token, anyone could sign their own requests.
+		return nil, errors.New("-inboundaddr requires -twtoken to check request signatures")
+	}
 
-	flag.Parse()
+	porterTLS, err := porterTLSConfig(o.porterCACert, o.porterClientCert, o.porterClientKey, o.porterInsecure)
+	if err != nil {
+		return nil, err
+	}
+	if o.porterAuth != "apikey" && o.porterAuth != "bearer" {
+		return nil, errors.New("-pauth must be 'apikey' or 'bearer'")
+	}
+	controllers, err := parseControllers(o.porterApiURIs.values, o.porterApiKeys.values, porterTLS, o.porterAuth == "bearer", o.porterUserAgent)
+	if err != nil {
+		return nil, err
+	}
+	if o.porterInsecure {
+		slog.Warn("not verifying the Porter API's TLS certificate; use -pinsecure for local testing only")
+	}
 
-	if *accountSID == "" || *twilioAuthToken == "" || *sender == "" || *rcptList == "" || *porterApiKey == "" || *porterApiURI == "" {
-		flag.PrintDefaults()
-		os.Exit(1)
+	cfg.OpenThreshold = time.Duration(o.openTime) * time.Minute
+	cfg.RepeatThreshold = time.Duration(o.notifyTime) * time.Minute
+	cfg.RepeatMax = time.Duration(o.repeatMax) * time.Minute
+	cfg.CallThreshold = time.Duration(o.callTime) * time.Minute
+	cfg.FlapWindow = time.Duration(o.flapWindow) * time.Minute
+	cfg.MissingAfter = time.Duration(o.missingAfter) * time.Minute
+	cfg.MaxDailyOpen = time.Duration(o.maxDailyOpen) * time.Minute
+	cfg.CloseDebounce = time.Duration(o.closeDebounce) * time.Second
+	cfg.StartupGrace = time.Duration(o.startupGrace) * time.Minute
+	cfg.PollInterval = time.Duration(o.pollTime) * time.Second
+	cfg.PollTimeout = time.Duration(o.pollTimeout) * time.Second
+	cfg.MaxStateAge = time.Duration(o.maxAge) * time.Hour
+
+	if o.timezone != "" {
+		loc, err := time.LoadLocation(o.timezone)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TimeLocation = loc
+	}
+
+	cfg.DisabledMsgs = make(map[int]bool)
+	for _, name := range splitList(o.disableMsgs) {
+		msgType, ok := msgTypeByName(name)
+		if !ok {
+			return nil, fmt.Errorf("-disablemsgs: unknown message %q", name)
+		}
+		cfg.DisabledMsgs[msgType] = true
 	}
 
-	porterClient = client.NewClient()
-	porterClient.APIKey = *porterApiKey
-	porterClient.HostURI = *porterApiURI
+	if o.templateFile != "" {
+		if err := loadTemplateFile(o.templateFile); err != nil {
+			return nil, err
+		}
+	}
+	msgFlags := map[int]string{
+		MsgStateChangeOpen:   o.msgOpen,
+		MsgStateChangeClosed: o.msgClosed,
+		MsgMonitorStarting:   o.msgStarting,
+		MsgMonitorDying:      o.msgStopping,
+		MsgMonitorError:      o.msgError,
+		MsgMonitorRecover:    o.msgRecover,
+	}
+	for msgType, text := range msgFlags {
+		if text == "" {
+			continue
+		}
+		if err := setMsgTemplate(msgType, text); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.DoorThresholds, err = parseDoorThresholds(o.doorThresh)
+	if err != nil {
+		return nil, err
+	}
+	cfg.OffDayThreshold = time.Duration(o.offDayTime) * time.Minute
+	cfg.OffDayDoorThresholds, err = parseDoorThresholds(o.offDayDoorThresh)
+	if err != nil {
+		return nil, err
+	}
+	cfg.OffDays, err = parseWeekdays(o.offDays)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Holidays, err = parseHolidays(o.holidays)
+	if err != nil {
+		return nil, err
+	}
+	cfg.DoorNames, err = parseDoorMap(o.doorNames, "name")
+	if err != nil {
+		return nil, err
+	}
 
-	openNotificationThreshold = time.Duration(*openTime) * time.Minute
-	repeatNotificationThreshold = time.Duration(*notifyTime) * time.Minute
+	if o.digestTime != "" {
+		at, err := parseClock(o.digestTime)
+		if err != nil {
+			return nil, err
+		}
+		cfg.DigestAt = at
+	}
 
-	recipients = strings.Split(*rcptList, ",")
+	if o.quietStart != "" || o.quietEnd != "" {
+		start, err := parseClock(o.quietStart)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(o.quietEnd)
+		if err != nil {
+			return nil, err
+		}
+		if o.quietMode != "drop" && o.quietMode != "defer" {
+			return nil, fmt.Errorf("invalid -quietmode %q, expected 'drop' or 'defer'", o.quietMode)
+		}
+		cfg.QuietHours = &QuietHours{Start: start, End: end, Defer: o.quietMode == "defer"}
+	}
 
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
-	signal.Notify(sig, os.Kill)
-	signal.Notify(sig, syscall.SIGTERM)
+	var smsLimiter *rateLimiter
+	if o.smsRate > 0 {
+		smsLimiter = newRateLimiter(o.smsRate, 1)
+	}
 
-	sendAll(genMsg(MsgMonitorStarting))
-	go statusMonitor()
+	var notifiers []Notifier
+	var phoneLists [10][]string
+	for i, list := range []string{o.sender, o.senderList, o.rcptList, o.escalateList, o.criticalList, o.callList, o.whatsAppList, o.whatsAppSender, o.signalNumber, o.signalList} {
+		phoneLists[i], err = normalizePhones(list, o.defaultRegion)
+		if err != nil {
+			return nil, err
+		}
+	}
+	senders := append(phoneLists[0], phoneLists[1]...)
+	recipients, escalateRecipients, criticalRecipients, callRecipients := phoneLists[2], phoneLists[3], phoneLists[4], phoneLists[5]
+	whatsAppRecipients, whatsAppSenders := phoneLists[6], phoneLists[7]
+	signalNumbers, signalRecipients := phoneLists[8], phoneLists[9]
+
+	contacts, err := parseContacts(o.contactList.values, o.defaultRegion)
+	if err != nil {
+		return nil, err
+	}
+	recipients = mergeRecipients(recipients, contactAddresses(contacts, "sms"))
+	var personalNumbers, langs []string
+	for _, c := range contacts {
+		if c.personal() && c.Channel == "sms" {
+			personalNumbers = append(personalNumbers, c.Address)
+		}
+		if c.Lang != "" {
+			langs = append(langs, c.Lang)
+		}
+	}
+	langTemplates, err := loadLangTemplates(o.langDir, langs)
+	if err != nil {
+		return nil, err
+	}
+	emailRecipients := contactAddresses(contacts, "email")
+	if o.emailTo != "" {
+		emailRecipients = mergeRecipients(splitList(o.emailTo), emailRecipients)
+	}
+	tgRecipients := contactAddresses(contacts, "telegram")
+	if o.tgChatIDs != "" {
+		tgRecipients = mergeRecipients(splitList(o.tgChatIDs), tgRecipients)
+	}
 
-	for {
-		select {
-		case <-sig:
-			fmt.Printf("%v Porter Twilio: Stopping daemon...\n", time.Now())
-			sendAll(genMsg(MsgMonitorDying))
-			time.Sleep(3 * time.Second)
-			os.Exit(0)
+	flagRecipients := recipients
+	if o.recipientsFile != "" {
+		fileRecipients, err := readRecipientsFile(o.recipientsFile, o.defaultRegion)
+		if err != nil {
+			return nil, err
 		}
+		recipients = mergeRecipients(flagRecipients, fileRecipients)
 	}
 
-}
+	if len(senders) > 0 && o.msgService != "" {
+		return nil, errors.New("-twsender/-twsenders and -twmsgservice are mutually exclusive")
+	}
+	twilioSender := len(senders) > 0 || o.msgService != ""
 
-func statusMonitor() {
-	doors := make(map[string]*DoorWatch)
-	var errorMsgSent bool
+	snapshotURLs, err := parseDoorMap(o.doorSnapshots, "URL")
+	if err != nil {
+		return nil, err
+	}
 
-	for {
-		select {
+	var budget *smsBudget
+	if o.maxSMSPerDay > 0 {
+		budget = newSMSBudget(o.maxSMSPerDay, cfg.TimeLocation, clock)
+	}
 
-		default:
-			time.Sleep(5 * time.Second)
+	twilioConfigured := o.accountSID != "" && o.twilioAuthToken != "" && twilioSender
+	missingTwilioFlags := func() string {
+		var missing []string
+		if o.accountSID == "" {
+			missing = append(missing, "-twsid")
+		}
+		if o.twilioAuthToken == "" {
+			missing = append(missing, "-twtoken")
+		}
+		if !twilioSender {
+			missing = append(missing, "-twsender or -twmsgservice")
+		}
+		return strings.Join(missing, ", ")
+	}
+	smsNotifier := func(recipients []string) *TwilioNotifier {
+		return &TwilioNotifier{
+			AccountSID:          o.accountSID,
+			AuthToken:           o.twilioAuthToken,
+			Senders:             senders,
+			MessagingServiceSID: o.msgService,
+			Recipients:          recipients,
+			Retries:             o.sendRetries,
+			Concurrency:         o.sendConcurrency,
+			SnapshotURL:         o.snapshotURL,
+			DoorSnapshotURLs:    snapshotURLs,
+			SplitLong:           o.splitLongSMS,
+			Budget:              budget,
+			Limiter:             smsLimiter,
+			HTTPClient:          &http.Client{Timeout: time.Duration(o.twTimeout) * time.Second},
+		}
+	}
 
-			states, err := porterClient.List()
-			if err != nil {
-				if !errorMsgSent {
-					errorMsgSent = true
-					sendAll(genMsg(MsgMonitorError))
+	for _, c := range contacts {
+		configured := true
+		switch c.Channel {
+		case "sms":
+			configured = twilioConfigured
+		case "email":
+			configured = o.smtpHost != "" && o.emailFrom != ""
+		case "telegram":
+			configured = o.tgToken != ""
+		}
+		if !configured {
+			return nil, fmt.Errorf("contact %s:%s: the %s channel is not configured", c.Channel, c.Address, c.Channel)
+		}
+	}
+	if len(recipients) > 0 && !twilioConfigured {
+		return nil, fmt.Errorf("-recipients requires %s", missingTwilioFlags())
+	}
+
+	var sms *TwilioNotifier
+	if twilioConfigured && len(recipients) > 0 {
+		sms = smsNotifier(recipients)
+		notifiers = append(notifiers, sms)
+	}
+	if len(whatsAppRecipients) > 0 {
+		if o.accountSID == "" || o.twilioAuthToken == "" || (len(whatsAppSenders) == 0 && !twilioSender) {
+			return nil, errors.New("-whatsapprecipients requires -twsid, -twtoken and -whatsappsender, -twsender or -twmsgservice")
+		}
+		// WhatsApp messages aren't billed as SMS segments, so they are
+		// neither split nor counted against -maxsmsperday.
+		wa := smsNotifier(whatsAppRecipients)
+		wa.WhatsApp = true
+		wa.SplitLong = false
+		wa.Budget = nil
+		if len(whatsAppSenders) > 0 {
+			wa.Senders = whatsAppSenders
+			wa.MessagingServiceSID = ""
+		}
+		notifiers = append(notifiers, wa)
+	}
+	var escalationNotifier Notifier
+	if len(escalateRecipients) > 0 && cfg.EscalateAfter > 0 {
+		if !twilioConfigured {
+			return nil, fmt.Errorf("-escalate requires %s", missingTwilioFlags())
+		}
+		escalationNotifier = smsNotifier(escalateRecipients)
+	}
+	var voiceNotifier Notifier
+	if len(callRecipients) > 0 && cfg.CallThreshold > 0 {
+		if o.accountSID == "" || o.twilioAuthToken == "" || len(senders) == 0 {
+			return nil, errors.New("-callrecipients requires -twsid, -twtoken and -twsender")
+		}
+		voiceNotifier = &TwilioVoiceNotifier{
+			AccountSID: o.accountSID,
+			AuthToken:  o.twilioAuthToken,
+			From:       senders[0],
+			Recipients: callRecipients,
+			HTTPClient: &http.Client{Timeout: time.Duration(o.twTimeout) * time.Second},
+		}
+	}
+	var criticalNotifier Notifier
+	if len(criticalRecipients) > 0 {
+		if !twilioConfigured {
+			return nil, fmt.Errorf("-criticalrecipients requires %s", missingTwilioFlags())
+		}
+		criticalNotifier = smsNotifier(criticalRecipients)
+	}
+	emailNotifier := func(to []string) *EmailNotifier {
+		return &EmailNotifier{
+			Host:     o.smtpHost,
+			Port:     o.smtpPort,
+			Username: o.smtpUser,
+			Password: o.smtpPass,
+			From:     o.emailFrom,
+			To:       to,
+		}
+	}
+	if o.smtpHost != "" && o.emailFrom != "" && len(emailRecipients) > 0 {
+		notifiers = append(notifiers, emailNotifier(emailRecipients))
+	}
+	if o.slackWebhook != "" {
+		notifiers = append(notifiers, &SlackNotifier{WebhookURL: o.slackWebhook})
+	}
+	if o.discordWebhook != "" {
+		notifiers = append(notifiers, &DiscordNotifier{WebhookURL: o.discordWebhook})
+	}
+	if o.tgToken != "" && len(tgRecipients) > 0 {
+		notifiers = append(notifiers, &TelegramNotifier{Token: o.tgToken, ChatIDs: tgRecipients})
+	}
+	if o.webhookURL != "" {
+		wh, err := NewWebhookNotifier(o.webhookURL, o.webhookBody, o.webhookContentType)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, wh)
+	}
+	if o.ntfyURL != "" {
+		notifiers = append(notifiers, &NtfyNotifier{URL: o.ntfyURL, Token: o.ntfyToken})
+	}
+	// Channels that track door state are also told of every open and
+	// close directly.
+	var observers []DoorObserver
+	if o.pdRoutingKey != "" {
+		pd := &PagerDutyNotifier{RoutingKey: o.pdRoutingKey}
+		notifiers = append(notifiers, pd)
+		observers = append(observers, pd)
+	}
+	if o.opsgenieKey != "" {
+		og := &OpsgenieNotifier{APIKey: o.opsgenieKey, URL: o.opsgenieURL}
+		notifiers = append(notifiers, og)
+		observers = append(observers, og)
+	}
+	if o.pushoverToken != "" || o.pushoverUser != "" {
+		switch {
+		case o.pushoverToken == "" || o.pushoverUser == "":
+			return nil, errors.New("Pushover notifications require -pushovertoken and -pushoveruser")
+		case o.pushoverRetry < 30:
+			return nil, errors.New("-pushoverretry must be at least 30")
+		case o.pushoverExpire > 10800:
+			return nil, errors.New("-pushoverexpire must be at most 10800")
+		}
+		notifiers = append(notifiers, &PushoverNotifier{
+			Token:  o.pushoverToken,
+			User:   o.pushoverUser,
+			Retry:  time.Duration(o.pushoverRetry) * time.Second,
+			Expire: time.Duration(o.pushoverExpire) * time.Second,
+		})
+	}
+	if o.signalURL != "" || len(signalRecipients) > 0 {
+		if o.signalURL == "" || len(signalNumbers) != 1 || len(signalRecipients) == 0 {
+			return nil, errors.New("Signal notifications require -signalurl, a single -signalnumber and -signalrecipients")
+		}
+		notifiers = append(notifiers, &SignalNotifier{URL: o.signalURL, Number: signalNumbers[0], Recipients: signalRecipients})
+	}
+	if o.matrixHomeserver != "" || o.matrixRoom != "" {
+		if o.matrixHomeserver == "" || o.matrixRoom == "" || o.matrixToken == "" {
+			return nil, errors.New("Matrix notifications require -matrixhomeserver, -matrixroom and -matrixtoken")
+		}
+		notifiers = append(notifiers, &MatrixNotifier{Homeserver: o.matrixHomeserver, RoomID: o.matrixRoom, AccessToken: o.matrixToken})
+	}
+	if o.mqttBroker != "" {
+		if o.mqttQoS != 0 && o.mqttQoS != 1 {
+			return nil, errors.New("-mqttqos must be 0 or 1")
+		}
+		mq := &MQTTNotifier{
+			Broker:   o.mqttBroker,
+			Topic:    o.mqttTopic,
+			ClientID: o.mqttClientID,
+			Username: o.mqttUser,
+			Password: o.mqttPass,
+			QoS:      byte(o.mqttQoS),
+			Retain:   o.mqttRetain,
+		}
+		notifiers = append(notifiers, mq)
+		observers = append(observers, mq)
+	}
+
+	// Contacts with settings of their own are sent to separately, and always
+	// in addition to the other channels.
+	shared := len(notifiers)
+	for _, c := range contacts {
+		if !c.personal() {
+			continue
+		}
+		var n Notifier
+		switch c.Channel {
+		case "sms":
+			n = smsNotifier([]string{c.Address})
+		case "email":
+			n = emailNotifier([]string{c.Address})
+		case "telegram":
+			n = &TelegramNotifier{Token: o.tgToken, ChatIDs: []string{c.Address}}
+		}
+		notifiers = append(notifiers, &ContactNotifier{Notifier: n, Contact: c, Clock: clock, Location: cfg.TimeLocation})
+	}
+
+	if len(notifiers) == 0 {
+		return nil, errors.New("no notification channels are configured; set -recipients along with -twsid, -twtoken and -twsender, or configure another channel such as -emailto or -slackwebhook (see -h)")
+	}
+
+	if o.breakerThreshold > 0 {
+		for i, n := range notifiers {
+			notifiers[i] = &BreakerNotifier{Notifier: n, Threshold: o.breakerThreshold, Cooldown: time.Duration(o.breakerCooldown) * time.Second, Clock: clock}
+		}
+	}
+
+	var audit *auditLog
+	if o.auditLogPath != "" {
+		if audit, err = openAuditLog(o.auditLogPath); err != nil {
+			return nil, err
+		}
+		for i, n := range notifiers {
+			notifiers[i] = &AuditNotifier{Notifier: n}
+		}
+		if escalationNotifier != nil {
+			escalationNotifier = &AuditNotifier{Notifier: escalationNotifier}
+		}
+		if criticalNotifier != nil {
+			criticalNotifier = &AuditNotifier{Notifier: criticalNotifier}
+		}
+		if voiceNotifier != nil {
+			voiceNotifier = &AuditNotifier{Notifier: voiceNotifier}
+		}
+	}
+
+	severities, err := parseChannelSeverities(o.channelSeverity)
+	if err != nil {
+		return nil, err
+	}
+	for i, n := range notifiers {
+		if min, ok := severities[channelName(n)]; ok {
+			notifiers[i] = &SeverityNotifier{Notifier: n, MinSeverity: min}
+		}
+	}
+	if o.dedupWindow > 0 {
+		for i, n := range notifiers {
+			notifiers[i] = &DedupNotifier{Notifier: n, Window: time.Duration(o.dedupWindow) * time.Second, Clock: clock}
+		}
+	}
+	if o.queueFile != "" {
+		for i, n := range notifiers {
+			notifiers[i] = &QueueNotifier{Notifier: n, ID: fmt.Sprintf("%s#%d", channelName(n), i)}
+		}
+		if escalationNotifier != nil {
+			escalationNotifier = &QueueNotifier{Notifier: escalationNotifier, ID: "escalation"}
+		}
+		if criticalNotifier != nil {
+			criticalNotifier = &QueueNotifier{Notifier: criticalNotifier, ID: "critical"}
+		}
+	}
+
+	var notifier Notifier
+	switch {
+	case o.dryRun:
+		notifier = &DryRunNotifier{Notifiers: notifiers}
+		if escalationNotifier != nil {
+			escalationNotifier = &DryRunNotifier{Notifiers: []Notifier{escalationNotifier}}
+		}
+		if criticalNotifier != nil {
+			criticalNotifier = &DryRunNotifier{Notifiers: []Notifier{criticalNotifier}}
+		}
+		if voiceNotifier != nil {
+			voiceNotifier = &DryRunNotifier{Notifiers: []Notifier{voiceNotifier}}
+		}
+	case o.notifyMode == "fanout":
+		notifier = &MultiNotifier{Notifiers: notifiers}
+	case o.notifyMode == "fallback" && shared < len(notifiers):
+		notifier = &MultiNotifier{Notifiers: append([]Notifier{&FallbackNotifier{Notifiers: notifiers[:shared]}}, notifiers[shared:]...)}
+	case o.notifyMode == "fallback":
+		notifier = &FallbackNotifier{Notifiers: notifiers}
+	default:
+		return nil, fmt.Errorf("invalid -notifymode %q, expected 'fanout' or 'fallback'", o.notifyMode)
+	}
+
+	app := NewApp(cfg, controllers, notifier, escalationNotifier)
+	app.clock = clock
+	app.criticalNotifier = criticalNotifier
+	app.langTemplates = langTemplates
+	app.auditLog = audit
+	if o.eventStore != "" {
+		app.events = NewFileEventStore(o.eventStore)
+	}
+	if o.queueFile != "" {
+		app.queue, err = openNotifyQueue(o.queueFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	app.voiceNotifier = voiceNotifier
+	if !o.dryRun {
+		app.observers = observers
+	}
+
+	if budget != nil {
+		var others []Notifier
+		for _, n := range notifiers {
+			if channelName(n) != "sms" {
+				others = append(others, n)
+			}
+		}
+		if len(others) > 0 {
+			budget.onExhausted = func() {
+				if !app.msgEnabled(MsgSMSBudget) {
+					return
 				}
-				continue
+				ev := Event{Type: MsgSMSBudget, Severity: msgSeverity(MsgSMSBudget)}
+				app.deliverTo(context.Background(), &MultiNotifier{Notifiers: others}, ev, app.genMsg(MsgSMSBudget))
 			}
+		}
+	}
 
-			if errorMsgSent {
-				errorMsgSent = false
-				sendAll(genMsg(MsgMonitorRecover))
+	app.channels = notifiers
+	if o.inboundAddr != "" {
+		app.inbound = &inboundHandler{
+			app:          app,
+			authToken:    o.twilioAuthToken,
+			publicURL:    o.inboundURL,
+			skipSigCheck: o.skipSigCheck,
+		}
+		app.inbound.setAllowed(recipients, escalateRecipients, criticalRecipients, personalNumbers)
+	}
+	if o.recipientsFile != "" {
+		app.reloadRecipients = func() error {
+			fileRecipients, err := readRecipientsFile(o.recipientsFile, o.defaultRegion)
+			if err != nil {
+				return err
 			}
+			recipients := mergeRecipients(flagRecipients, fileRecipients)
+			if sms != nil {
+				sms.SetRecipients(recipients)
+			}
+			if app.inbound != nil {
+				app.inbound.setAllowed(recipients, escalateRecipients, criticalRecipients, personalNumbers)
+			}
+			slog.Info("reloaded recipients", "count", len(recipients))
+			return nil
+		}
+	}
+
+	return app, nil
+}
+
+// runDaemon serves the HTTP endpoints and polls until the process is told to
+// stop.
+func runDaemon(app *App, o *options, status *statusView) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	signal.Notify(sig, os.Kill)
+	signal.Notify(sig, syscall.SIGTERM)
 
-			for doorName, state := range states {
-				if _, ok := doors[doorName]; !ok {
-					doors[doorName] = &DoorWatch{
-						lastStateChangeTS:    state.LastStateChangeTimestamp,
-						lastNotificationSent: time.Time{},
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if o.healthAddr != "" {
+		health.staleAfter = time.Duration(o.healthStale) * time.Second
+		mux := http.NewServeMux()
+		mux.Handle("/healthz", health)
+		srv := serveHTTP(o.healthAddr, mux)
+		defer srv.Close()
+	}
+
+	if app.inbound != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/sms", app.inbound)
+		srv := serveHTTP(o.inboundAddr, mux)
+		defer srv.Close()
+	}
+
+	if app.reloadRecipients != nil || app.auditLog != nil {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if app.auditLog != nil {
+					if err := app.auditLog.reopen(); err != nil {
+						slog.Error("failed to reopen audit log", "error", err)
 					}
 				}
-
-				if state.SensorClosedState == state.State {
-					if doors[doorName].lastStateChangeTS != state.LastStateChangeTimestamp && !doors[doorName].lastNotificationSent.IsZero() {
-						delete(doors, doorName)
-						sendAll(genMsg(MsgStateChangeClosed, doorName, time.Since(state.LastStateChangeTimestamp)))
+				if app.reloadRecipients != nil {
+					if err := app.reloadRecipients(); err != nil {
+						slog.Error("keeping previous recipients", "error", err)
 					}
-					continue
 				}
+			}
+		}()
+	}
 
-				if time.Since(state.LastStateChangeTimestamp) < openNotificationThreshold {
-					continue
-				}
+	if o.apiAddr != "" {
+		srv := serveHTTP(o.apiAddr, app.apiHandler(o.apiKey))
+		defer srv.Close()
+	}
 
-				if doors[doorName].lastStateChangeTS == state.LastStateChangeTimestamp && !doors[doorName].lastNotificationSent.IsZero() {
-					if time.Since(doors[doorName].lastNotificationSent) < repeatNotificationThreshold {
-						continue
-					}
-				}
+	if o.metricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics)
+		srv := serveHTTP(o.metricsAddr, mux)
+		defer srv.Close()
+	}
 
-				doors[doorName].lastNotificationSent = time.Now()
-				doors[doorName].lastStateChangeTS = state.LastStateChangeTimestamp
+	if o.notifyOnStart {
+		app.notify(ctx, MsgMonitorStarting)
+	}
 
-				sendAll(genMsg(MsgStateChangeOpen, doorName, time.Since(state.LastStateChangeTimestamp)))
-			}
-		}
+	if systemd != nil && systemd.watchdog > 0 && systemd.watchdog < 2*app.cfg.PollInterval {
+		slog.Warn("systemd watchdog interval is shorter than two poll intervals", "watchdog", systemd.watchdog, "poll", app.cfg.PollInterval)
+	}
+	systemd.ready()
+
+	started := app.clock.Now()
+	monitorDone := make(chan struct{})
+	go func() {
+		app.run(ctx)
+		close(monitorDone)
+	}()
+
+	statusDone := make(chan struct{})
+	if status != nil {
+		go func() {
+			status.run(ctx, app)
+			close(statusDone)
+		}()
+	} else {
+		close(statusDone)
+	}
 
+	<-sig
+	slog.Info("stopping daemon")
+	systemd.stopping()
+
+	cancel()
+	<-monitorDone
+	<-statusDone
+	app.inflight.Wait()
+
+	summary := metrics.summary(app.clock.Since(started))
+	slog.Info("shutdown summary", "uptime", summary.Uptime.Round(time.Second), "polls", summary.Polls, "poll_errors", summary.PollErrors, "sent", summary.Sent)
+
+	if o.notifyOnStop {
+		if o.stopSummary {
+			app.notify(ctx, MsgMonitorDying, summary)
+		} else {
+			app.notify(ctx, MsgMonitorDying)
+		}
 	}
 }
 
-func genMsg(msgType int, values ...interface{}) string {
-	const openStateStr = "[%v] Porter notice: %s has been open for %v."
-	const closedStateStr = "[%v] Porter notice: %s is now closed."
-	const startStr = "[%v] Porter notice: Door monitor started."
-	const stopStr = "[%v] Porter notice: Door monitor is stopping."
-	const errorStr = "[%v] Porter notice: I'm having trouble reaching the door controller. The network might be offline, or the controller may need to be rebooted. I won't send any more messages until I can reach it."
-	const recoverStr = "[%v] Porter notice: The garage door controller is back online! Status updates will resume."
-
-	currentTime := time.Now()
-	timeStr := currentTime.Format("Mon Jan 2 '06 3:4 PM")
-
-	switch msgType {
-	case MsgStateChangeOpen:
-		return fmt.Sprintf(openStateStr, timeStr, values[0], durafmt.ParseShort(values[1].(time.Duration)).String())
-	case MsgStateChangeClosed:
-		return fmt.Sprintf(closedStateStr, timeStr, values[0])
-	case MsgMonitorDying:
-		return fmt.Sprintf(stopStr, timeStr)
-	case MsgMonitorStarting:
-		return fmt.Sprintf(startStr, timeStr)
-	case MsgMonitorError:
-		return fmt.Sprintf(errorStr, timeStr)
-	case MsgMonitorRecover:
-		return fmt.Sprintf(recoverStr, timeStr)
+// stringList is a flag that may be repeated or given comma-separated values.
+// The first use replaces the default.
+type stringList struct {
+	values []string
+	set    bool
+}
 
-	default:
+func (l *stringList) String() string {
+	if l == nil {
 		return ""
 	}
+	return strings.Join(l.values, ",")
 }
 
-func sendAll(msg string) {
-	wg := &sync.WaitGroup{}
-	for _, number := range recipients {
-		go (func(wg *sync.WaitGroup, from, to string) {
-			wg.Add(1)
-			sendSMS(from, to, msg)
-			wg.Done()
-		})(wg, *sender, number)
+func (l *stringList) Set(v string) error {
+	if !l.set {
+		l.values = nil
+		l.set = true
 	}
-	wg.Wait()
+	l.values = append(l.values, splitList(v)...)
+	return nil
 }
 
-func sendSMS(sender, recipient, message string) int {
-	httpClient := &http.Client{}
-	httpClient.Timeout = 30 * time.Second
+// splitList splits a comma-separated list, trimming whitespace around each
+// item and dropping empty ones, such as from a trailing comma.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
 
-	apiUrl := strings.Join([]string{"https://api.twilio.com/2010-04-01/Accounts/", *accountSID, "/Messages.json"}, "")
+// parseControllers pairs each Porter URI, optionally written 'label=URI', with
+// its API key. A single key applies to every controller. With bearer set, the
+// key is sent as a bearer token instead of the client's own API key scheme. An
+// empty userAgent uses the default.
+func parseControllers(uris, keys []string, tlsConfig *tls.Config, bearer bool, userAgent string) ([]*controller, error) {
+	if len(uris) == 0 {
+		return nil, fmt.Errorf("at least one -papi is required")
+	}
+	if len(keys) != 1 && len(keys) != len(uris) {
+		return nil, fmt.Errorf("got %d -pkey values for %d -papi values", len(keys), len(uris))
+	}
 
-	v := url.Values{}
-	v.Set("To", recipient)
-	v.Set("From", sender)
-	v.Set("Body", message)
-	payload := *strings.NewReader(v.Encode())
+	var cs []*controller
+	labels := make(map[string]bool)
+	for i, uri := range uris {
+		label := ""
+		if l, u, ok := strings.Cut(uri, "="); ok && !strings.ContainsAny(l, ":/") {
+			label, uri = l, u
+		}
+		if len(uris) > 1 && label == "" {
+			return nil, fmt.Errorf("-papi %q needs a label ('label=URI') when monitoring several controllers", uri)
+		}
+		if labels[label] {
+			return nil, fmt.Errorf("duplicate controller label %q", label)
+		}
+		labels[label] = true
 
-	req, _ := http.NewRequest("POST", apiUrl, &payload)
+		key := keys[0]
+		if len(keys) > 1 {
+			key = keys[i]
+		}
+		if uri == "" || key == "" {
+			return nil, fmt.Errorf("-papi and -pkey must not be empty")
+		}
+		if u, err := url.Parse(uri); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid -papi %q, expected a URL such as http://10.0.0.5:8080", uri)
+		}
 
-	req.SetBasicAuth(*accountSID, *twilioAuthToken)
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		c := client.NewClient()
+		c.HostURI = uri
+		token := ""
+		if bearer {
+			token = key
+		} else {
+			c.APIKey = key
+		}
+		if s, ok := PorterClient(c).(httpClientSetter); ok {
+			s.SetHTTPClient(newPorterHTTPClient(tlsConfig, token, userAgent))
+		} else if tlsConfig != nil || bearer {
+			return nil, fmt.Errorf("-pauth bearer and the Porter TLS options need a Porter client that supports a custom HTTP client")
+		} else if userAgent != "" {
+			slog.Warn("Porter client doesn't support a custom HTTP client; ignoring -puseragent", "controller", label)
+		}
+		cs = append(cs, &controller{label: label, client: c})
+	}
 
-	if res, err := httpClient.Do(req); err != nil {
-		return -1
-	} else {
-		return res.StatusCode
+	return cs, nil
+}
+
+// parseDoorMap parses 'door=value,...' into a map. what names the value in
+// error messages.
+func parseDoorMap(s, what string) (map[string]string, error) {
+	m := make(map[string]string)
+	if s == "" {
+		return m, nil
 	}
+
+	for _, entry := range strings.Split(s, ",") {
+		name, v, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(v) == "" {
+			return nil, fmt.Errorf("invalid door %s %q, expected name=%s", strings.ToLower(what), entry, what)
+		}
+		m[strings.TrimSpace(name)] = strings.TrimSpace(v)
+	}
+
+	return m, nil
+}
+
+func parseDoorThresholds(s string) (map[string]time.Duration, error) {
+	thresholds := make(map[string]time.Duration)
+	if s == "" {
+		return thresholds, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		name, minutes, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid door threshold %q, expected name=minutes", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(minutes))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid door threshold %q, expected name=minutes", entry)
+		}
+		thresholds[strings.TrimSpace(name)] = time.Duration(n) * time.Minute
+	}
+
+	return thresholds, nil
+}
+
+const dateLayout = "2006-01-02"
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseWeekdays parses a list of days like 'sat,sun'. Full day names are
+// accepted too.
+func parseWeekdays(s string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	for _, name := range splitList(s) {
+		key := strings.ToLower(name)
+		if len(key) > 3 {
+			key = key[:3]
+		}
+		day, ok := weekdayNames[key]
+		if !ok {
+			return nil, fmt.Errorf("invalid day of the week %q, expected e.g. 'sat'", name)
+		}
+		days[day] = true
+	}
+	return days, nil
+}
+
+func parseHolidays(s string) (map[string]bool, error) {
+	dates := make(map[string]bool)
+	for _, date := range splitList(s) {
+		t, err := time.Parse(dateLayout, date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday %q, expected YYYY-MM-DD", date)
+		}
+		dates[t.Format(dateLayout)] = true
+	}
+	return dates, nil
+}
+
+// checkNonNegativeFlags rejects negative values for numeric flags, none of
+// which has a meaning for them.
+func checkNonNegativeFlags(fs *flag.FlagSet) error {
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			return
+		}
+		switch v := getter.Get().(type) {
+		case int:
+			if v < 0 {
+				errs = append(errs, fmt.Errorf("-%s must not be negative", f.Name))
+			}
+		case float64:
+			if v < 0 {
+				errs = append(errs, fmt.Errorf("-%s must not be negative", f.Name))
+			}
+		}
+	})
+	return errors.Join(errs...)
 }
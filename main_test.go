@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestBuildApp(t *testing.T) {
+	twilio := []string{"-twsid", "AC123", "-twtoken", "token", "-twsender", "+18005550100"}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string // empty if buildApp should succeed
+	}{
+		{"sms", append([]string{"-recipients", "+18005550199"}, twilio...), ""},
+		{"other channel", []string{"-slackwebhook", "https://hooks.example.com/x"}, ""},
+		{"no recipients", twilio, "no notification channels are configured"},
+		{"recipients without twilio", []string{"-recipients", "+18005550199", "-twsid", "AC123"}, "-recipients requires -twtoken, -twsender or -twmsgservice"},
+		{"malformed porter URI", []string{"-slackwebhook", "https://hooks.example.com/x", "-papi", "10.0.0.5:8080"}, "invalid -papi"},
+		{"porter URI without a host", []string{"-slackwebhook", "https://hooks.example.com/x", "-papi", "http://"}, "invalid -papi"},
+		{"poll interval", []string{"-slackwebhook", "https://hooks.example.com/x", "-pollinterval", "0"}, "-pollinterval must be at least 1"},
+		{"inbound without a token", []string{"-slackwebhook", "https://hooks.example.com/x", "-inboundaddr", ":8082"}, "-inboundaddr requires -twtoken"},
+		{"unknown disabled message", []string{"-slackwebhook", "https://hooks.example.com/x", "-disablemsgs", "closed,opn"}, `-disablemsgs: unknown message "opn"`},
+		{"bad recipient", append([]string{"-recipients", "+1800555"}, twilio...), "+1800555"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("reporter", flag.ContinueOnError)
+			o := registerFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			app, err := buildApp(o)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("buildApp() error = %v", err)
+			case tt.wantErr == "" && len(app.channels) != 1:
+				t.Errorf("got %d channels, want 1", len(app.channels))
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("buildApp() error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
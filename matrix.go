@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// MatrixNotifier sends messages to a Matrix room through the client-server
+// API.
+type MatrixNotifier struct {
+	Homeserver  string // e.g. https://matrix.example.org
+	RoomID      string // e.g. !abc123:example.org
+	AccessToken string
+
+	txn atomic.Uint64
+}
+
+type matrixMessage struct {
+	MsgType string `json:"msgtype"`
+	Body    string `json:"body"`
+}
+
+func (m *MatrixNotifier) Send(ctx context.Context, msg string) error {
+	body, err := json.Marshal(matrixMessage{MsgType: "m.text", Body: msg})
+	if err != nil {
+		return err
+	}
+
+	// Retries reuse the transaction ID, so the homeserver drops a resend of
+	// a message that did arrive rather than posting it twice.
+	txnID := fmt.Sprintf("porter-%d-%d", time.Now().UnixNano(), m.txn.Add(1))
+	endpoint := strings.TrimSuffix(m.Homeserver, "/") + "/_matrix/client/v3/rooms/" +
+		url.PathEscape(m.RoomID) + "/send/m.room.message/" + url.PathEscape(txnID)
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("building matrix request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+m.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	res, err := postWithRetry(ctx, httpClient, req, webhookRetryPolicy)
+	if err != nil {
+		return fmt.Errorf("sending to matrix: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		mErr := &matrixError{}
+		json.NewDecoder(io.LimitReader(res.Body, 4096)).Decode(mErr)
+		mErr.Status = res.StatusCode
+		return mErr
+	}
+
+	return nil
+}
+
+// matrixError is a non-2xx response from the homeserver.
+type matrixError struct {
+	Status  int
+	ErrCode string `json:"errcode"`
+	Message string `json:"error"`
+}
+
+func (e *matrixError) Error() string {
+	return fmt.Sprintf("matrix returned HTTP %d: %s %s", e.Status, e.ErrCode, e.Message)
+}
+
+func (e *matrixError) httpStatus() int { return e.Status }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMatrixNotifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int // successive responses; the last repeats
+		wantErr  string
+	}{
+		{"delivered", []int{http.StatusOK}, ""},
+		{"retried with the same transaction", []int{http.StatusBadGateway, http.StatusOK}, ""},
+		{"rejected", []int{http.StatusForbidden}, "matrix returned HTTP 403: M_FORBIDDEN not in room"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var mu sync.Mutex
+			var paths []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPut {
+					t.Errorf("method %s, want PUT", r.Method)
+				}
+				if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+					t.Errorf("Authorization %q", auth)
+				}
+				var msg matrixMessage
+				if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || msg.MsgType != "m.text" || msg.Body != "Garage is open." {
+					t.Errorf("body %+v, %v", msg, err)
+				}
+
+				mu.Lock()
+				paths = append(paths, r.URL.EscapedPath())
+				status := tt.statuses[min(len(paths), len(tt.statuses))-1]
+				mu.Unlock()
+				w.WriteHeader(status)
+				if status == http.StatusForbidden {
+					w.Write([]byte(`{"errcode": "M_FORBIDDEN", "error": "not in room"}`))
+				}
+			}))
+			defer srv.Close()
+
+			m := &MatrixNotifier{Homeserver: srv.URL + "/", RoomID: "!abc123:example.org", AccessToken: "secret"}
+			err := m.Send(context.Background(), "Garage is open.")
+			if tt.wantErr != "" {
+				var mErr *matrixError
+				if !errors.As(err, &mErr) || err.Error() != tt.wantErr {
+					t.Fatalf("Send() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(paths) != len(tt.statuses) {
+				t.Fatalf("made %d requests, want %d", len(paths), len(tt.statuses))
+			}
+			const prefix = "/_matrix/client/v3/rooms/%21abc123:example.org/send/m.room.message/porter-"
+			for _, p := range paths {
+				if !strings.HasPrefix(p, prefix) {
+					t.Errorf("path %s, want prefix %s", p, prefix)
+				}
+				if p != paths[0] {
+					t.Errorf("retry went to %s, want the same transaction as %s", p, paths[0])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hako/durafmt"
+)
+
+// pollLatencyBuckets are the upper bounds, in seconds, of the poll latency
+// histogram.
+var pollLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metricsRegistry holds the counters exposed on /metrics in the Prometheus
+// text format. It is small enough that pulling in the client library isn't
+// worth it.
+type metricsRegistry struct {
+	mu                sync.Mutex
+	notificationsSent map[string]uint64
+	smsFailures       uint64
+	pollErrors        uint64
+	doorsOpen         int
+	pollBuckets       []uint64
+	pollCount         uint64
+	pollSum           float64
+}
+
+var metrics = &metricsRegistry{
+	notificationsSent: make(map[string]uint64),
+	pollBuckets:       make([]uint64, len(pollLatencyBuckets)),
+}
+
+func (m *metricsRegistry) notificationSent(msgType int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notificationsSent[eventName(msgType)]++
+}
+
+func (m *metricsRegistry) smsFailed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.smsFailures++
+}
+
+func (m *metricsRegistry) pollFailed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pollErrors++
+}
+
+func (m *metricsRegistry) setDoorsOpen(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.doorsOpen = n
+}
+
+func (m *metricsRegistry) observePoll(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	secs := d.Seconds()
+	for i, le := range pollLatencyBuckets {
+		if secs <= le {
+			m.pollBuckets[i]++
+		}
+	}
+	m.pollCount++
+	m.pollSum += secs
+}
+
+// activitySummary is what happened during a run, for the shutdown summary.
+type activitySummary struct {
+	Uptime     time.Duration
+	Polls      uint64
+	PollErrors uint64
+	Sent       map[string]uint64 // by message type
+}
+
+func (s activitySummary) String() string {
+	var total uint64
+	types := make([]string, 0, len(s.Sent))
+	for t, n := range s.Sent {
+		total += n
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "Ran for %s: %d polls, %d poll errors, %d notifications sent", durafmt.ParseShort(s.Uptime), s.Polls, s.PollErrors, total)
+	for i, t := range types {
+		if i == 0 {
+			b.WriteString(" (")
+		} else {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(b, "%s %d", t, s.Sent[t])
+	}
+	if len(types) > 0 {
+		b.WriteString(")")
+	}
+	b.WriteString(".")
+	return b.String()
+}
+
+func (m *metricsRegistry) summary(uptime time.Duration) activitySummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sent := make(map[string]uint64, len(m.notificationsSent))
+	for t, n := range m.notificationsSent {
+		sent[t] = n
+	}
+	return activitySummary{Uptime: uptime, Polls: m.pollCount, PollErrors: m.pollErrors, Sent: sent}
+}
+
+func (m *metricsRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP porter_notifications_sent_total Notifications delivered, by message type.")
+	fmt.Fprintln(w, "# TYPE porter_notifications_sent_total counter")
+	types := make([]string, 0, len(m.notificationsSent))
+	for t := range m.notificationsSent {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Fprintf(w, "porter_notifications_sent_total{type=%q} %d\n", t, m.notificationsSent[t])
+	}
+
+	fmt.Fprintln(w, "# HELP porter_sms_failures_total SMS messages that could not be delivered.")
+	fmt.Fprintln(w, "# TYPE porter_sms_failures_total counter")
+	fmt.Fprintf(w, "porter_sms_failures_total %d\n", m.smsFailures)
+
+	fmt.Fprintln(w, "# HELP porter_poll_errors_total Failed polls of the Porter API.")
+	fmt.Fprintln(w, "# TYPE porter_poll_errors_total counter")
+	fmt.Fprintf(w, "porter_poll_errors_total %d\n", m.pollErrors)
+
+	fmt.Fprintln(w, "# HELP porter_doors_open Doors currently reported open.")
+	fmt.Fprintln(w, "# TYPE porter_doors_open gauge")
+	fmt.Fprintf(w, "porter_doors_open %d\n", m.doorsOpen)
+
+	fmt.Fprintln(w, "# HELP porter_poll_duration_seconds Latency of Porter API polls.")
+	fmt.Fprintln(w, "# TYPE porter_poll_duration_seconds histogram")
+	for i, le := range pollLatencyBuckets {
+		fmt.Fprintf(w, "porter_poll_duration_seconds_bucket{le=%q} %d\n", strconv.FormatFloat(le, 'g', -1, 64), m.pollBuckets[i])
+	}
+	fmt.Fprintf(w, "porter_poll_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.pollCount)
+	fmt.Fprintf(w, "porter_poll_duration_seconds_sum %g\n", m.pollSum)
+	fmt.Fprintf(w, "porter_poll_duration_seconds_count %d\n", m.pollCount)
+}
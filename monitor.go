@@ -0,0 +1,589 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"porter/client"
+	"strings"
+	"time"
+)
+
+// PorterClient is the part of the Porter API client the monitor uses, so that
+// it can be substituted.
+type PorterClient interface {
+	List() (map[string]*client.DoorState, error)
+}
+
+// contextLister is implemented by clients that can cancel a List request.
+type contextLister interface {
+	ListContext(ctx context.Context) (map[string]*client.DoorState, error)
+}
+
+// listDoors fetches door states, giving up when ctx is done or after timeout
+// (if non-zero). Clients without ListContext are called in a goroutine that is
+// abandoned, not interrupted, if it hangs: it runs on until List returns, then
+// drops its result into the buffered channel and exits. A controller that
+// never answers leaks one such goroutine per poll, so clients should bound
+// their own requests too.
+func listDoors(ctx context.Context, pc PorterClient, timeout time.Duration) (map[string]*client.DoorState, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if cl, ok := pc.(contextLister); ok {
+		return cl.ListContext(ctx)
+	}
+
+	type result struct {
+		states map[string]*client.DoorState
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		states, err := pc.List()
+		done <- result{states, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.states, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// doorGetter is implemented by clients that can fetch a single door.
+type doorGetter interface {
+	GetContext(ctx context.Context, name string) (*client.DoorState, error)
+}
+
+// errDoorNotFound is returned by getDoor for a door the controller doesn't
+// list.
+var errDoorNotFound = errors.New("door not found")
+
+// getDoor fetches the state of one door. Clients without GetContext list
+// every door and pick out the one asked for.
+func getDoor(ctx context.Context, pc PorterClient, name string, timeout time.Duration) (*client.DoorState, error) {
+	if g, ok := pc.(doorGetter); ok {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return g.GetContext(ctx, name)
+	}
+
+	states, err := listDoors(ctx, pc, timeout)
+	if err != nil {
+		return nil, err
+	}
+	state, ok := states[name]
+	if !ok {
+		return nil, fmt.Errorf("%q: %w", name, errDoorNotFound)
+	}
+	return state, nil
+}
+
+// controller is a single Porter instance being monitored. When more than one
+// is configured, each has a label that prefixes its door names.
+type controller struct {
+	label  string
+	client PorterClient
+
+	errorMsgSent bool
+	reconciled   bool
+	interval     time.Duration
+	nextPoll     time.Time
+	views        []doorView
+
+	// clockWarned records the bad timestamp last warned about per door, so
+	// each is only reported once.
+	clockWarned map[string]time.Time
+
+	// malformed records doors whose state is currently unusable, so each is
+	// only logged once.
+	malformed map[string]bool
+}
+
+// doorKey is the name a door is tracked and reported under.
+func (c *controller) doorKey(doorName string) string {
+	if c.label == "" {
+		return doorName
+	}
+	return c.label + "/" + doorName
+}
+
+func (c *controller) ownsKey(key string) bool {
+	return c.label == "" || strings.HasPrefix(key, c.label+"/")
+}
+
+// controllerFor returns the controller a door key belongs to and the door's
+// name on that controller, or nil if no controller owns the key.
+func (a *App) controllerFor(key string) (*controller, string) {
+	for _, c := range a.controllers {
+		if c.label == "" {
+			return c, key
+		}
+		if rawName, ok := strings.CutPrefix(key, c.label+"/"); ok {
+			return c, rawName
+		}
+	}
+	return nil, ""
+}
+
+// monitor holds what statusMonitor knows about each door between polls.
+type monitor struct {
+	app         *App
+	doors       map[string]*DoorWatch
+	controllers []*controller
+
+	// due collects open notifications to send together when -batchnotify
+	// is set.
+	due []batchDoor
+
+	digest     digestStats
+	nextDigest time.Time
+
+	startedAt time.Time
+}
+
+func newMonitor(app *App) *monitor {
+	saved, err := loadState(app.cfg.StateFile)
+	if err != nil {
+		slog.Warn("ignoring saved state", "error", err)
+		saved = &savedState{Doors: make(map[string]*DoorWatch)}
+	}
+
+	app.mutes.restore(saved.Mutes)
+	app.loadOpenCounts()
+
+	for _, c := range app.controllers {
+		c.errorMsgSent = saved.ErrorNotified[c.label]
+		c.interval = app.cfg.PollInterval
+	}
+
+	return &monitor{app: app, doors: saved.Doors, controllers: app.controllers, startedAt: app.clock.Now()}
+}
+
+// poll fetches door states from every controller that is due, sends any
+// notifications and saves state if it changed. A controller that fails is
+// backed off without affecting the others; their errors are returned joined.
+func (m *monitor) poll(ctx context.Context) error {
+	var errs []error
+	changed, polled := false, false
+	now := m.app.clock.Now()
+
+	for _, c := range m.controllers {
+		if now.Before(c.nextPoll) {
+			continue
+		}
+
+		c.nextPoll = now.Add(c.interval)
+		polled = true
+		controllerChanged, err := m.pollController(ctx, c)
+		changed = changed || controllerChanged
+		if err != nil {
+			errs = append(errs, err)
+
+			c.interval *= 2
+			if c.interval > maxPollBackoff {
+				c.interval = maxPollBackoff
+			}
+			c.nextPoll = now.Add(c.interval)
+			continue
+		}
+
+		c.interval = m.app.cfg.PollInterval
+		c.nextPoll = time.Time{}
+	}
+
+	m.flushBatch(ctx)
+
+	doorsOpen := 0
+	var views []doorView
+	for _, c := range m.controllers {
+		for _, v := range c.views {
+			if v.Open {
+				doorsOpen++
+			}
+		}
+		views = append(views, c.views...)
+	}
+	metrics.setDoorsOpen(doorsOpen)
+	m.app.latestDoors.set(views)
+
+	err := errors.Join(errs...)
+	health.record(err)
+	if polled && err == nil {
+		systemd.ping()
+	}
+
+	if _, mutesChanged := m.app.mutes.snapshot(); mutesChanged || changed {
+		m.save()
+	}
+
+	return err
+}
+
+func (m *monitor) pollController(ctx context.Context, c *controller) (bool, error) {
+	cfg := &m.app.cfg
+	doors := m.doors
+
+	logger := slog.With("controller", c.label)
+
+	pollStart := m.app.clock.Now()
+	states, err := listDoors(ctx, c.client, cfg.PollTimeout)
+	metrics.observePoll(m.app.clock.Since(pollStart))
+	if err != nil && ctx.Err() != nil {
+		// Shutting down; not the controller's fault.
+		return false, err
+	}
+	if err != nil {
+		logger.Warn("poll failed", "error", err)
+		metrics.pollFailed()
+		if c.errorMsgSent {
+			return false, err
+		}
+		c.errorMsgSent = true
+		if c.label == "" {
+			m.app.notify(ctx, MsgMonitorError)
+		} else {
+			m.app.notify(ctx, MsgMonitorError, c.label)
+		}
+		if c.label != "" {
+			err = fmt.Errorf("%s: %w", c.label, err)
+		}
+		return true, err
+	}
+
+	logger.Debug("poll succeeded", "doors", len(states), "duration", m.app.clock.Since(pollStart))
+
+	changed := false
+	if c.errorMsgSent {
+		c.errorMsgSent = false
+		changed = true
+		if c.label == "" {
+			m.app.notify(ctx, MsgMonitorRecover)
+		} else {
+			m.app.notify(ctx, MsgMonitorRecover, c.label)
+		}
+	}
+
+	if !c.reconciled {
+		c.reconciled = true
+		present := make(map[string]bool, len(states))
+		for doorName := range states {
+			present[c.doorKey(doorName)] = true
+		}
+		changed = reconcileState(doors, c.ownsKey, present) || changed
+	}
+
+	prev := make(map[string]bool, len(c.views))
+	for _, v := range c.views {
+		prev[v.Name] = v.Open
+	}
+
+	c.views = make([]doorView, 0, len(states))
+	for rawName, state := range states {
+		doorName := c.doorKey(rawName)
+
+		// One bad entry shouldn't stop the other doors being monitored.
+		if problem := malformedState(rawName, state); problem != "" {
+			if c.malformed == nil {
+				c.malformed = make(map[string]bool)
+			}
+			if !c.malformed[doorName] {
+				c.malformed[doorName] = true
+				logger.Warn("skipping door with unusable state", "door", doorName, "problem", problem)
+			}
+			continue
+		}
+		if c.malformed[doorName] {
+			delete(c.malformed, doorName)
+			logger.Info("door state is usable again", "door", doorName)
+		}
+
+		open := state.SensorClosedState != state.State
+
+		c.views = append(c.views, doorView{
+			Name:       doorName,
+			Open:       open,
+			LastChange: state.LastStateChangeTimestamp,
+		})
+
+		if wasOpen, ok := prev[doorName]; ok && wasOpen != open {
+			logger.Info("door state changed", "door", doorName, "open", open)
+		}
+
+		_, known := doors[doorName]
+		if !known {
+			changed = true
+			doors[doorName] = &DoorWatch{
+				lastStateChangeTS:    state.LastStateChangeTimestamp,
+				lastNotificationSent: time.Time{},
+			}
+		}
+		doors[doorName].lastSeen = pollStart
+		if doors[doorName].missingNotified {
+			doors[doorName].missingNotified = false
+			logger.Info("door is reporting again", "door", doorName)
+		}
+
+		if state.SensorClosedState == state.State {
+			// Wait for the close to settle; if the door reopens first, the
+			// close is never acted on.
+			if m.app.clock.Since(state.LastStateChangeTimestamp) < cfg.CloseDebounce {
+				continue
+			}
+
+			m.app.mutes.unmute(doorName)
+			w := doors[doorName]
+			if w.closed && w.lastClosed.Equal(state.LastStateChangeTimestamp) {
+				continue
+			}
+
+			changed = true
+			w.closed = true
+			w.lastClosed = state.LastStateChangeTimestamp
+			// A door first seen closed didn't close just now, so there's
+			// nothing to count.
+			if known {
+				if !w.lastOpened.IsZero() && w.lastClosed.After(w.lastOpened) {
+					m.digest.recordOpenDuration(doorName, w.lastClosed.Sub(w.lastOpened))
+					m.app.opens.recordClose(doorName, w.lastOpened, w.lastClosed, cfg.TimeLocation)
+				}
+				m.noteTransition(ctx, doorName, w)
+				m.app.recordEvent(EventRecord{Time: state.LastStateChangeTimestamp, Kind: "close", Door: doorName})
+			}
+			m.app.doorChanged(ctx, DoorChange{Door: doorName, At: state.LastStateChangeTimestamp, Alerted: !w.lastNotificationSent.IsZero()})
+
+			if w.lastStateChangeTS != state.LastStateChangeTimestamp && !w.lastNotificationSent.IsZero() && !m.isFlapping(doorName, w) {
+				m.app.notify(ctx, MsgStateChangeClosed, doorName, m.app.clock.Since(state.LastStateChangeTimestamp), state.LastStateChangeTimestamp)
+			}
+
+			// Start the next open event afresh.
+			w.lastStateChangeTS = state.LastStateChangeTimestamp
+			w.lastNotificationSent = time.Time{}
+			w.repeats = 0
+			continue
+		}
+
+		if doors[doorName].closed {
+			changed = true
+			doors[doorName].closed = false
+		}
+		if !doors[doorName].lastOpened.Equal(state.LastStateChangeTimestamp) {
+			changed = true
+			doors[doorName].lastOpened = state.LastStateChangeTimestamp
+			// Likewise for a door first seen open, though it's still
+			// alerted on once open long enough.
+			if known {
+				m.digest.recordOpen()
+				m.app.opens.record(doorName, state.LastStateChangeTimestamp, cfg.TimeLocation)
+				m.noteTransition(ctx, doorName, doors[doorName])
+				m.app.recordEvent(EventRecord{Time: state.LastStateChangeTimestamp, Kind: "open", Door: doorName})
+			}
+			m.app.doorChanged(ctx, DoorChange{Door: doorName, Open: true, At: state.LastStateChangeTimestamp})
+		}
+
+		if problem := m.timestampProblem(state.LastStateChangeTimestamp); problem != "" {
+			if c.clockWarned == nil {
+				c.clockWarned = make(map[string]time.Time)
+			}
+			if !c.clockWarned[doorName].Equal(state.LastStateChangeTimestamp) {
+				c.clockWarned[doorName] = state.LastStateChangeTimestamp
+				slog.Warn("skipping door with implausible state change time", "door", doorName, "last_change", state.LastStateChangeTimestamp, "problem", problem)
+				if cfg.ClockAlert {
+					m.app.notify(ctx, MsgClockSkew, doorName)
+				}
+			}
+			continue
+		}
+		delete(c.clockWarned, doorName)
+
+		w := doors[doorName]
+		if m.isFlapping(doorName, w) {
+			// Opens held back while flapping aren't announced late once it
+			// settles.
+			if w.openedTS != state.LastStateChangeTimestamp {
+				changed = true
+				w.openedTS = state.LastStateChangeTimestamp
+			}
+			continue
+		}
+
+		if cfg.NotifyOnOpen && known && w.lastStateChangeTS != state.LastStateChangeTimestamp && w.openedTS != state.LastStateChangeTimestamp {
+			changed = true
+			w.openedTS = state.LastStateChangeTimestamp
+			m.app.notify(ctx, MsgDoorOpened, doorName, m.app.clock.Since(state.LastStateChangeTimestamp), state.LastStateChangeTimestamp)
+		}
+
+		if cfg.MaxDailyOpen > 0 && !m.app.mutes.isMuted(doorName, m.app.clock.Now()) {
+			if total, over := m.app.opens.overLimit(doorName, state.LastStateChangeTimestamp, m.app.clock.Now(), cfg.MaxDailyOpen, cfg.TimeLocation); over {
+				m.app.notify(ctx, MsgDailyOpenLimit, doorName, total)
+			}
+		}
+
+		if m.app.clock.Since(state.LastStateChangeTimestamp) < m.app.openThreshold(doorName, rawName) || m.app.mutes.isMuted(doorName, m.app.clock.Now()) {
+			continue
+		}
+
+		// Doors already open when we started wait out -startupgrace, so a
+		// quick restart doesn't re-alert on them straight away.
+		if state.LastStateChangeTimestamp.Before(m.startedAt) && m.app.clock.Since(m.startedAt) < cfg.StartupGrace {
+			continue
+		}
+
+		if m.app.voiceNotifier != nil && m.app.clock.Since(state.LastStateChangeTimestamp) >= cfg.CallThreshold && !w.calledTS.Equal(state.LastStateChangeTimestamp) {
+			changed = true
+			w.calledTS = state.LastStateChangeTimestamp
+			m.app.call(ctx, doorName, m.app.clock.Since(state.LastStateChangeTimestamp))
+		}
+
+		if doors[doorName].lastStateChangeTS == state.LastStateChangeTimestamp && !doors[doorName].lastNotificationSent.IsZero() {
+			// A repeat threshold of 0 means one notification per open event.
+			if cfg.RepeatThreshold == 0 {
+				continue
+			}
+			if m.app.clock.Since(doors[doorName].lastNotificationSent) < cfg.repeatInterval(doors[doorName].repeats) {
+				continue
+			}
+			if cfg.MaxRepeats > 0 && doors[doorName].repeats >= cfg.MaxRepeats {
+				continue
+			}
+		}
+
+		// Hold the alert back during quiet hours without counting it as
+		// sent, so a door still open when they end is alerted on then.
+		if cfg.QuietHours.suppresses(MsgStateChangeOpen, m.app.clock.Now().In(cfg.TimeLocation)) {
+			continue
+		}
+
+		if doors[doorName].lastStateChangeTS == state.LastStateChangeTimestamp && !doors[doorName].lastNotificationSent.IsZero() {
+			doors[doorName].repeats++
+		} else {
+			doors[doorName].repeats = 0
+		}
+
+		changed = true
+		doors[doorName].lastNotificationSent = m.app.clock.Now()
+		doors[doorName].lastStateChangeTS = state.LastStateChangeTimestamp
+
+		due := batchDoor{
+			Name:      doorName,
+			Duration:  m.app.clock.Since(state.LastStateChangeTimestamp),
+			Escalated: cfg.EscalateAfter > 0 && doors[doorName].repeats >= cfg.EscalateAfter,
+			Changed:   state.LastStateChangeTimestamp,
+		}
+		switch {
+		case cfg.BatchNotify:
+			m.due = append(m.due, due)
+		case due.Escalated:
+			m.app.notifyEscalated(ctx, MsgStateChangeOpen, due.Name, due.Duration, due.Changed)
+		default:
+			m.app.notify(ctx, MsgStateChangeOpen, due.Name, due.Duration, due.Changed)
+		}
+	}
+
+	m.checkMissing(ctx, c, states)
+
+	return changed, nil
+}
+
+// checkMissing sends a notice for each door of c that has dropped out of its
+// list for longer than cfg.MissingAfter, such as when a sensor is unplugged.
+func (m *monitor) checkMissing(ctx context.Context, c *controller, states map[string]*client.DoorState) {
+	if m.app.cfg.MissingAfter <= 0 {
+		return
+	}
+
+	present := make(map[string]bool, len(states))
+	for rawName := range states {
+		present[c.doorKey(rawName)] = true
+	}
+
+	for doorName, w := range m.doors {
+		if !c.ownsKey(doorName) || present[doorName] || w.missingNotified {
+			continue
+		}
+		if w.lastSeen.IsZero() {
+			// Known only from saved state; start counting now.
+			w.lastSeen = m.app.clock.Now()
+			continue
+		}
+		if m.app.clock.Since(w.lastSeen) >= m.app.cfg.MissingAfter {
+			w.missingNotified = true
+			slog.Warn("door is no longer reporting", "door", doorName, "last_seen", w.lastSeen)
+			m.app.notify(ctx, MsgDoorMissing, doorName)
+		}
+	}
+}
+
+// malformedState describes what makes a door entry from the controller
+// unusable, or returns "" if it can be used.
+func malformedState(rawName string, state *client.DoorState) string {
+	switch {
+	case strings.TrimSpace(rawName) == "":
+		return "empty door name"
+	case state == nil:
+		return "no state"
+	case state.LastStateChangeTimestamp.IsZero():
+		return "no state change time"
+	}
+	return ""
+}
+
+// clockSkewTolerance allows for small clock differences between the
+// controller and this host before a timestamp is considered to be in the
+// future.
+const clockSkewTolerance = time.Minute
+
+// timestampProblem describes why a door's state change time can't be trusted,
+// or returns "" if it looks sane.
+func (m *monitor) timestampProblem(ts time.Time) string {
+	maxAge := m.app.cfg.MaxStateAge
+	since := m.app.clock.Since(ts)
+	switch {
+	case since < -clockSkewTolerance:
+		return "in the future"
+	case maxAge > 0 && since > maxAge:
+		return "implausibly old"
+	default:
+		return ""
+	}
+}
+
+// flushBatch sends the open notifications collected during a poll, combining
+// them into one message when there are several.
+func (m *monitor) flushBatch(ctx context.Context) {
+	due := m.due
+	m.due = nil
+
+	switch {
+	case len(due) == 0:
+	case len(due) == 1 && due[0].Escalated:
+		m.app.notifyEscalated(ctx, MsgStateChangeOpen, due[0].Name, due[0].Duration, due[0].Changed)
+	case len(due) == 1:
+		m.app.notify(ctx, MsgStateChangeOpen, due[0].Name, due[0].Duration, due[0].Changed)
+	default:
+		m.app.notifyBatch(ctx, due)
+	}
+}
+
+func (m *monitor) save() {
+	state := &savedState{Doors: m.doors, ErrorNotified: make(map[string]bool)}
+	state.Mutes, _ = m.app.mutes.snapshot()
+	for _, c := range m.controllers {
+		if c.errorMsgSent {
+			state.ErrorNotified[c.label] = true
+		}
+	}
+
+	if err := saveState(m.app.cfg.StateFile, state); err != nil {
+		slog.Error("failed to save state", "error", err)
+	}
+}
@@ -0,0 +1,772 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"porter/client"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// pollStart is when runPollSteps starts its clock, a Friday morning.
+var pollStart = time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+
+// stubDoor is a door as the controller reports it during a pollStep.
+type stubDoor struct {
+	name  string
+	open  bool
+	since time.Duration // when it last changed, from pollStart
+}
+
+// pollStep is one poll made by runPollSteps.
+type pollStep struct {
+	at    time.Duration // from pollStart
+	doors []stubDoor    // what the controller reports; nil keeps the last step's
+	err   error         // fails the poll if set
+
+	// want has a substring of each message the poll should send.
+	want []string
+}
+
+// runPollSteps polls a single stubPorter controller once per step with the
+// clock set to the step's time, checking the messages each poll sends. setup
+// can adjust the App before the monitor is created.
+func runPollSteps(t *testing.T, cfg Config, steps []pollStep, setup ...func(*App)) *App {
+	t.Helper()
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = time.Minute
+	}
+	if cfg.TimeFormat == "" {
+		cfg.TimeFormat = time.Kitchen
+	}
+
+	clock := newFakeClock(pollStart)
+	porter := &stubPorter{}
+	rec := &recordingNotifier{}
+	app := NewApp(cfg, []*controller{{client: porter}}, rec, nil)
+	app.clock = clock
+	for _, f := range setup {
+		f(app)
+	}
+	m := newMonitor(app)
+
+	for _, step := range steps {
+		clock.Set(pollStart.Add(step.at))
+		porter.mu.Lock()
+		porter.err = step.err
+		if step.doors != nil {
+			porter.doors = nil
+		}
+		porter.mu.Unlock()
+		for _, d := range step.doors {
+			porter.set(d.name, d.open, pollStart.Add(d.since))
+		}
+
+		before := len(rec.sent())
+		m.poll(context.Background())
+		got := rec.sent()[before:]
+
+		if len(got) != len(step.want) {
+			t.Fatalf("poll at %v sent %q, want %d messages matching %q", step.at, got, len(step.want), step.want)
+		}
+		// Doors are polled in no particular order, so neither are the
+		// messages about them.
+		unmatched := append([]string(nil), got...)
+		for _, want := range step.want {
+			i := 0
+			for i < len(unmatched) && !strings.Contains(unmatched[i], want) {
+				i++
+			}
+			if i == len(unmatched) {
+				t.Errorf("poll at %v sent %q, want one mentioning %q", step.at, got, want)
+				continue
+			}
+			unmatched = append(unmatched[:i], unmatched[i+1:]...)
+		}
+	}
+	return app
+}
+
+// listedPorter is a stubPorter that signals each List call.
+type listedPorter struct {
+	stubPorter
+	listed chan struct{}
+}
+
+func (p *listedPorter) List() (map[string]*client.DoorState, error) {
+	p.listed <- struct{}{}
+	return p.stubPorter.List()
+}
+
+func TestRunPollInterval(t *testing.T) {
+	for _, interval := range []time.Duration{time.Second, 5 * time.Second, 2 * time.Minute} {
+		t.Run(fmt.Sprint(interval), func(t *testing.T) {
+			clock := newFakeClock(time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC))
+			porter := &listedPorter{listed: make(chan struct{})}
+			cfg := Config{PollInterval: interval, TimeFormat: time.Kitchen, DigestAt: -1}
+			app := NewApp(cfg, []*controller{{client: porter}}, &recordingNotifier{}, nil)
+			app.clock = clock
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				app.run(ctx)
+				close(done)
+			}()
+			defer func() {
+				cancel()
+				<-done
+			}()
+
+			// Wait for run to start its ticker before moving the clock.
+			for {
+				clock.mu.Lock()
+				started := len(clock.tickers) > 0
+				clock.mu.Unlock()
+				if started {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			for i := 0; i < 3; i++ {
+				clock.Advance(interval - time.Millisecond)
+				select {
+				case <-porter.listed:
+					t.Fatalf("poll %d came before the interval was up", i+1)
+				case <-time.After(20 * time.Millisecond):
+				}
+
+				clock.Advance(time.Millisecond)
+				select {
+				case <-porter.listed:
+				case <-time.After(time.Second):
+					t.Fatalf("no poll %d after %v", i+1, interval)
+				}
+			}
+		})
+	}
+}
+
+// tickedClock is a fakeClock whose tickers start with a tick waiting.
+type tickedClock struct{ *fakeClock }
+
+func (c tickedClock) NewTicker(d time.Duration) Ticker {
+	t := c.fakeClock.NewTicker(d).(*fakeTicker)
+	t.c <- c.Now()
+	return t
+}
+
+// countingPorter counts the polls made through it.
+type countingPorter struct {
+	stubPorter
+	polls atomic.Int32
+}
+
+func (p *countingPorter) ListContext(ctx context.Context) (map[string]*client.DoorState, error) {
+	p.polls.Add(1)
+	return p.stubPorter.List()
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// With a tick and the cancellation both ready, select picks either; run
+	// must never poll.
+	porter := &countingPorter{}
+	for i := 0; i < 100; i++ {
+		app := NewApp(Config{PollInterval: time.Minute, TimeFormat: time.Kitchen, DigestAt: -1}, []*controller{{client: porter}}, &recordingNotifier{}, nil)
+		app.clock = tickedClock{newFakeClock(pollStart)}
+		app.run(ctx)
+	}
+	if n := porter.polls.Load(); n != 0 {
+		t.Errorf("polled %d times after cancellation", n)
+	}
+}
+
+func TestPollBackoff(t *testing.T) {
+	down := errors.New("connection refused")
+	const (
+		trouble = "trouble reaching the door controller"
+		back    = "back online"
+	)
+
+	// Each failure doubles the interval from 1m, up to maxPollBackoff; a
+	// poll made while backed off would send the recovery message early.
+	runPollSteps(t, Config{PollInterval: time.Minute}, []pollStep{
+		{at: 0, err: down, want: []string{trouble}},
+		{at: time.Minute},
+		{at: 2 * time.Minute, want: []string{back}},
+		{at: 3 * time.Minute, err: down, want: []string{trouble}},
+		{at: 4 * time.Minute, err: down},
+		{at: 5 * time.Minute, err: down},
+		{at: 8 * time.Minute},
+		{at: 9 * time.Minute, want: []string{back}},
+		{at: 10 * time.Minute, err: down, want: []string{trouble}},
+		{at: 12 * time.Minute, err: down},
+		{at: 16 * time.Minute, err: down},
+		{at: 20 * time.Minute},
+		{at: 21 * time.Minute, want: []string{back}},
+	})
+}
+
+func TestDoorThresholds(t *testing.T) {
+	doors := []stubDoor{{name: "garage", open: true}, {name: "shed", open: true}}
+
+	tests := []struct {
+		name       string
+		thresholds map[string]time.Duration
+		steps      []pollStep
+	}{
+		{"default only", nil, []pollStep{
+			{at: 0, doors: doors},
+			{at: 29 * time.Minute},
+			{at: 30 * time.Minute, want: []string{"garage", "shed"}},
+		}},
+		{"shorter for one door", map[string]time.Duration{"shed": 5 * time.Minute}, []pollStep{
+			{at: 0, doors: doors},
+			{at: 4 * time.Minute},
+			{at: 5 * time.Minute, want: []string{"shed"}},
+			{at: 30 * time.Minute, want: []string{"garage"}},
+		}},
+		{"longer for one door", map[string]time.Duration{"garage": 2 * time.Hour}, []pollStep{
+			{at: 0, doors: doors},
+			{at: 30 * time.Minute, want: []string{"shed"}},
+			{at: 119 * time.Minute},
+			{at: 2 * time.Hour, want: []string{"garage"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{OpenThreshold: 30 * time.Minute, DoorThresholds: tt.thresholds}
+			runPollSteps(t, cfg, tt.steps)
+		})
+	}
+}
+
+// taggedNotifier prefixes messages with tag before passing them on.
+type taggedNotifier struct {
+	tag      string
+	Notifier Notifier
+}
+
+func (n *taggedNotifier) Send(ctx context.Context, msg string) error {
+	return n.Notifier.Send(ctx, n.tag+msg)
+}
+
+func TestEscalation(t *testing.T) {
+	const escalated = "escalated: ["
+
+	tests := []struct {
+		name          string
+		escalateAfter int
+		steps         []pollStep
+	}{
+		{"disabled", 0, []pollStep{
+			{at: 0, doors: []stubDoor{{name: "garage", open: true}}},
+			{at: 30 * time.Minute, want: []string{"garage has been open"}},
+			{at: 90 * time.Minute, want: []string{"garage has been open"}},
+			{at: 150 * time.Minute, want: []string{"garage has been open"}},
+		}},
+		{"after two repeats", 2, []pollStep{
+			{at: 0, doors: []stubDoor{{name: "garage", open: true}}},
+			{at: 30 * time.Minute, want: []string{"garage has been open"}},
+			{at: 90 * time.Minute, want: []string{"garage has been open"}},
+			{at: 150 * time.Minute, want: []string{escalated, "garage has been open"}},
+			{at: 210 * time.Minute, want: []string{escalated, "garage has been open"}},
+			{at: 220 * time.Minute, doors: []stubDoor{{name: "garage", since: 220 * time.Minute}}, want: []string{"garage is now closed"}},
+			// A new open event starts from the first tier again.
+			{at: 230 * time.Minute, doors: []stubDoor{{name: "garage", open: true, since: 230 * time.Minute}}},
+			{at: 260 * time.Minute, want: []string{"garage has been open"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{OpenThreshold: 30 * time.Minute, RepeatThreshold: time.Hour, EscalateAfter: tt.escalateAfter}
+			runPollSteps(t, cfg, tt.steps, func(app *App) {
+				app.escalationNotifier = &taggedNotifier{tag: "escalated: ", Notifier: app.notifier}
+			})
+		})
+	}
+}
+
+func TestNotifyOnOpen(t *testing.T) {
+	closed := []stubDoor{{name: "garage"}}
+	opened := []stubDoor{{name: "garage", open: true, since: 5 * time.Minute}}
+
+	tests := []struct {
+		name         string
+		notifyOnOpen bool
+		steps        []pollStep
+	}{
+		{"disabled", false, []pollStep{
+			{at: 0, doors: closed},
+			{at: 5 * time.Minute, doors: opened},
+			{at: 35 * time.Minute, want: []string{"garage has been open"}},
+		}},
+		{"enabled", true, []pollStep{
+			{at: 0, doors: closed},
+			{at: 5 * time.Minute, doors: opened, want: []string{"garage was just opened"}},
+			{at: 6 * time.Minute},
+			{at: 35 * time.Minute, want: []string{"garage has been open"}},
+		}},
+		{"already open at startup", true, []pollStep{
+			{at: 5 * time.Minute, doors: opened},
+			{at: 35 * time.Minute, want: []string{"garage has been open"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runPollSteps(t, Config{OpenThreshold: 30 * time.Minute, NotifyOnOpen: tt.notifyOnOpen}, tt.steps)
+		})
+	}
+}
+
+func TestDoorHistoryKept(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	cfg := Config{OpenThreshold: 30 * time.Minute, RepeatThreshold: time.Hour, StateFile: stateFile}
+
+	runPollSteps(t, cfg, []pollStep{
+		{at: 0, doors: []stubDoor{{name: "garage", open: true}}},
+		{at: 30 * time.Minute, want: []string{"garage has been open for 30 minutes"}},
+		{at: 90 * time.Minute, want: []string{"garage has been open for 1 hour"}},
+		{at: 100 * time.Minute, doors: []stubDoor{{name: "garage", since: 100 * time.Minute}}, want: []string{"garage is now closed"}},
+		{at: 110 * time.Minute, doors: []stubDoor{{name: "garage", open: true, since: 110 * time.Minute}}},
+		// The repeat count starts afresh: a first alert, not a third.
+		{at: 139 * time.Minute},
+		{at: 140 * time.Minute, want: []string{"garage has been open for 30 minutes"}},
+		{at: 199 * time.Minute},
+		{at: 200 * time.Minute, want: []string{"garage has been open for 1 hour"}},
+	})
+
+	saved, err := loadState(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, ok := saved.Doors["garage"]
+	switch {
+	case !ok:
+		t.Fatal("garage's watch wasn't kept")
+	case w.closed:
+		t.Error("garage is marked closed")
+	case !w.lastClosed.Equal(pollStart.Add(100 * time.Minute)):
+		t.Errorf("garage last closed %v, want the earlier close", w.lastClosed)
+	case !w.lastOpened.Equal(pollStart.Add(110 * time.Minute)):
+		t.Errorf("garage last opened %v, want the reopening", w.lastOpened)
+	case w.repeats != 1:
+		t.Errorf("garage has %d repeats, want 1", w.repeats)
+	}
+}
+
+func TestBatchNotify(t *testing.T) {
+	var many []stubDoor
+	for i := 0; i < 60; i++ {
+		many = append(many, stubDoor{name: fmt.Sprintf("loading-dock-door-%02d", i), open: true})
+	}
+
+	tests := []struct {
+		name  string
+		doors []stubDoor
+		want  []string
+	}{
+		{"one door", []stubDoor{{name: "garage", open: true}, {name: "shed"}}, []string{"garage has been open for 30 minutes"}},
+		{"several doors", []stubDoor{{name: "garage", open: true}, {name: "shed", open: true}, {name: "gate", open: true}},
+			[]string{"3 doors have been left open: "}},
+		{"split for SMS", many, []string{"60 doors have been left open: ", "loading-dock-door-"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := runPollSteps(t, Config{OpenThreshold: 30 * time.Minute, BatchNotify: true}, []pollStep{
+				{at: 0, doors: tt.doors},
+				{at: 30 * time.Minute, want: tt.want},
+			})
+			for _, msg := range app.notifier.(*recordingNotifier).sent() {
+				if len(msg) > twilioMaxBodyLength {
+					t.Errorf("message is %d bytes, over the SMS limit", len(msg))
+				}
+			}
+		})
+	}
+}
+
+func TestDisabledMsgs(t *testing.T) {
+	closed := []stubDoor{{name: "garage", since: 40 * time.Minute}, {name: "shed", since: 40 * time.Minute}}
+
+	tests := []struct {
+		name     string
+		disabled []int
+		batch    bool
+		steps    []pollStep
+	}{
+		{"closed", []int{MsgStateChangeClosed}, false, []pollStep{
+			{at: 0, doors: []stubDoor{{name: "garage", open: true}}},
+			{at: 30 * time.Minute, want: []string{"garage has been open"}},
+			{at: 40 * time.Minute, doors: closed[:1]},
+		}},
+		{"open covers batches", []int{MsgStateChangeOpen}, true, []pollStep{
+			{at: 0, doors: []stubDoor{{name: "garage", open: true}, {name: "shed", open: true}}},
+			{at: 30 * time.Minute},
+			{at: 40 * time.Minute, doors: closed, want: []string{"garage is now closed", "shed is now closed"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{OpenThreshold: 30 * time.Minute, BatchNotify: tt.batch, DisabledMsgs: make(map[int]bool)}
+			for _, msgType := range tt.disabled {
+				cfg.DisabledMsgs[msgType] = true
+			}
+			runPollSteps(t, cfg, tt.steps)
+		})
+	}
+}
+
+func TestMaxRepeats(t *testing.T) {
+	const open = "garage has been open"
+	opened := []stubDoor{{name: "garage", open: true}}
+
+	tests := []struct {
+		name       string
+		maxRepeats int
+		steps      []pollStep
+	}{
+		{"unlimited", 0, []pollStep{
+			{at: 0, doors: opened},
+			{at: 30 * time.Minute, want: []string{open}},
+			{at: 90 * time.Minute, want: []string{open}},
+			{at: 150 * time.Minute, want: []string{open}},
+			{at: 210 * time.Minute, want: []string{open}},
+		}},
+		{"capped at two", 2, []pollStep{
+			{at: 0, doors: opened},
+			{at: 30 * time.Minute, want: []string{open}},
+			{at: 90 * time.Minute, want: []string{open}},
+			{at: 150 * time.Minute, want: []string{open}},
+			{at: 210 * time.Minute},
+			{at: 270 * time.Minute},
+			{at: 280 * time.Minute, doors: []stubDoor{{name: "garage", since: 280 * time.Minute}}, want: []string{"garage is now closed"}},
+			{at: 290 * time.Minute, doors: []stubDoor{{name: "garage", open: true, since: 290 * time.Minute}}},
+			{at: 320 * time.Minute, want: []string{open}},
+			{at: 380 * time.Minute, want: []string{open}},
+			{at: 440 * time.Minute, want: []string{open}},
+			{at: 500 * time.Minute},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{OpenThreshold: 30 * time.Minute, RepeatThreshold: time.Hour, MaxRepeats: tt.maxRepeats}
+			runPollSteps(t, cfg, tt.steps)
+		})
+	}
+}
+
+func TestRepeatBackoff(t *testing.T) {
+	const open = "garage has been open"
+	cfg := Config{OpenThreshold: 30 * time.Minute, RepeatThreshold: time.Hour, RepeatBackoff: true, RepeatMax: 4 * time.Hour}
+
+	// Repeats come 1h, 2h, 4h and then 4h apart.
+	runPollSteps(t, cfg, []pollStep{
+		{at: 0, doors: []stubDoor{{name: "garage", open: true}}},
+		{at: 30 * time.Minute, want: []string{open}},
+		{at: 89 * time.Minute},
+		{at: 90 * time.Minute, want: []string{open}},
+		{at: 209 * time.Minute},
+		{at: 210 * time.Minute, want: []string{open}},
+		{at: 449 * time.Minute},
+		{at: 450 * time.Minute, want: []string{open}},
+		{at: 689 * time.Minute},
+		{at: 690 * time.Minute, want: []string{open}},
+	})
+}
+
+func TestNoRepeats(t *testing.T) {
+	const open = "garage has been open"
+	cfg := Config{OpenThreshold: 30 * time.Minute, RepeatThreshold: 0}
+
+	// Exactly one alert per time the door is opened, however long it stays
+	// open.
+	runPollSteps(t, cfg, []pollStep{
+		{at: 0, doors: []stubDoor{{name: "garage", open: true}}},
+		{at: 30 * time.Minute, want: []string{open}},
+		{at: 31 * time.Minute},
+		{at: 90 * time.Minute},
+		{at: 24 * time.Hour},
+		{at: 25 * time.Hour, doors: []stubDoor{{name: "garage", since: 25 * time.Hour}}, want: []string{"garage is now closed"}},
+		{at: 26 * time.Hour, doors: []stubDoor{{name: "garage", open: true, since: 26 * time.Hour}}},
+		{at: 26*time.Hour + 30*time.Minute, want: []string{open}},
+		{at: 30 * time.Hour},
+	})
+}
+
+func TestMaxDailyOpen(t *testing.T) {
+	const limit = "garage has been open for 30 minutes in total today"
+	door := func(open bool, since time.Duration) []stubDoor {
+		return []stubDoor{{name: "garage", open: open, since: since}}
+	}
+	cfg := Config{OpenThreshold: 2 * time.Hour, MaxDailyOpen: 30 * time.Minute, TimeLocation: time.UTC}
+
+	// Midnight is 16 hours after pollStart.
+	runPollSteps(t, cfg, []pollStep{
+		{at: 0, doors: door(false, -time.Hour)},
+		{at: time.Minute, doors: door(true, time.Minute)},
+		{at: 21 * time.Minute, doors: door(false, 21*time.Minute)},
+		{at: time.Hour, doors: door(true, time.Hour)},
+		{at: time.Hour + 9*time.Minute},
+		{at: time.Hour + 10*time.Minute, want: []string{limit}},
+		{at: time.Hour + 20*time.Minute},
+		{at: time.Hour + 30*time.Minute, doors: door(false, time.Hour+30*time.Minute)},
+		{at: 2 * time.Hour, doors: door(true, 2*time.Hour)},
+		{at: 3 * time.Hour, doors: door(false, 3*time.Hour)},
+		{at: 17 * time.Hour, doors: door(true, 17*time.Hour)},
+		{at: 17*time.Hour + 29*time.Minute},
+		{at: 17*time.Hour + 30*time.Minute, want: []string{limit}},
+	})
+}
+
+func TestFlapping(t *testing.T) {
+	const (
+		opened   = "garage was just opened"
+		flapping = "garage keeps opening and closing"
+	)
+	door := func(open bool, since time.Duration) []stubDoor {
+		return []stubDoor{{name: "garage", open: open, since: since}}
+	}
+
+	tests := []struct {
+		name      string
+		flapCount int
+		steps     []pollStep
+	}{
+		{"detection off", 0, []pollStep{
+			{at: 0, doors: door(false, 0)},
+			{at: time.Minute, doors: door(true, time.Minute), want: []string{opened}},
+			{at: 2 * time.Minute, doors: door(false, 2*time.Minute)},
+			{at: 3 * time.Minute, doors: door(true, 3*time.Minute), want: []string{opened}},
+			{at: 4 * time.Minute, doors: door(false, 4*time.Minute)},
+			{at: 5 * time.Minute, doors: door(true, 5*time.Minute), want: []string{opened}},
+			{at: 6 * time.Minute, doors: door(false, 6*time.Minute)},
+			{at: 7 * time.Minute, doors: door(true, 7*time.Minute), want: []string{opened}},
+		}},
+		{"more than three changes in ten minutes", 3, []pollStep{
+			{at: 0, doors: door(false, 0)},
+			{at: time.Minute, doors: door(true, time.Minute), want: []string{opened}},
+			{at: 2 * time.Minute, doors: door(false, 2*time.Minute)},
+			{at: 3 * time.Minute, doors: door(true, 3*time.Minute), want: []string{opened}},
+			{at: 4 * time.Minute, doors: door(false, 4*time.Minute), want: []string{flapping}},
+			{at: 5 * time.Minute, doors: door(true, 5*time.Minute)},
+			{at: 6 * time.Minute, doors: door(false, 6*time.Minute)},
+			{at: 7 * time.Minute, doors: door(true, 7*time.Minute)},
+			{at: 16 * time.Minute},
+			// Settled for a whole window, so alerts resume.
+			{at: 18 * time.Minute},
+			{at: 37 * time.Minute, want: []string{"garage has been open for 30 minutes"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{OpenThreshold: 30 * time.Minute, NotifyOnOpen: true, FlapCount: tt.flapCount, FlapWindow: 10 * time.Minute}
+			runPollSteps(t, cfg, tt.steps)
+		})
+	}
+}
+
+func TestCloseDebounce(t *testing.T) {
+	const closed = "garage is now closed"
+	door := func(open bool, since time.Duration) []stubDoor {
+		return []stubDoor{{name: "garage", open: open, since: since}}
+	}
+
+	tests := []struct {
+		name     string
+		debounce time.Duration
+		steps    []pollStep
+	}{
+		{"off", 0, []pollStep{
+			{at: 0, doors: door(true, 0)},
+			{at: 30 * time.Minute, want: []string{"garage has been open"}},
+			{at: 40 * time.Minute, doors: door(false, 40*time.Minute), want: []string{closed}},
+		}},
+		{"brief close", 2 * time.Minute, []pollStep{
+			{at: 0, doors: door(true, 0)},
+			{at: 30 * time.Minute, want: []string{"garage has been open"}},
+			{at: 40 * time.Minute, doors: door(false, 40*time.Minute)},
+			{at: 41 * time.Minute, doors: door(true, 41*time.Minute)},
+			{at: 43 * time.Minute},
+		}},
+		{"settled close", 2 * time.Minute, []pollStep{
+			{at: 0, doors: door(true, 0)},
+			{at: 30 * time.Minute, want: []string{"garage has been open"}},
+			{at: 40 * time.Minute, doors: door(false, 40*time.Minute)},
+			{at: 41 * time.Minute},
+			{at: 42 * time.Minute, want: []string{closed}},
+			{at: 43 * time.Minute},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runPollSteps(t, Config{OpenThreshold: 30 * time.Minute, RepeatThreshold: time.Hour, CloseDebounce: tt.debounce}, tt.steps)
+		})
+	}
+}
+
+func TestStartupGrace(t *testing.T) {
+	// garage was left open before we started; shed opens afterwards.
+	doors := []stubDoor{
+		{name: "garage", open: true, since: -2 * time.Hour},
+		{name: "shed", open: true, since: time.Minute},
+	}
+
+	tests := []struct {
+		name  string
+		grace time.Duration
+		steps []pollStep
+	}{
+		{"off", 0, []pollStep{
+			{at: 0, doors: doors, want: []string{"garage has been open for 2 hours"}},
+			{at: 31 * time.Minute, want: []string{"shed has been open for 30 minutes"}},
+		}},
+		{"held until the grace is over", 45 * time.Minute, []pollStep{
+			{at: 0, doors: doors},
+			{at: 31 * time.Minute, want: []string{"shed has been open for 30 minutes"}},
+			{at: 44 * time.Minute},
+			{at: 45 * time.Minute, want: []string{"garage has been open for 2 hours"}},
+			{at: 46 * time.Minute},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runPollSteps(t, Config{OpenThreshold: 30 * time.Minute, StartupGrace: tt.grace}, tt.steps)
+		})
+	}
+}
+
+func TestMissingDoor(t *testing.T) {
+	const missing = "shed is no longer reporting"
+	both := []stubDoor{{name: "garage"}, {name: "shed"}}
+	garageOnly := []stubDoor{{name: "garage"}}
+
+	tests := []struct {
+		name         string
+		missingAfter time.Duration
+		steps        []pollStep
+	}{
+		{"off", 0, []pollStep{
+			{at: 0, doors: both},
+			{at: time.Minute, doors: garageOnly},
+			{at: time.Hour},
+		}},
+		{"once per disappearance", 5 * time.Minute, []pollStep{
+			{at: 0, doors: both},
+			{at: time.Minute, doors: garageOnly},
+			{at: 4 * time.Minute},
+			{at: 5 * time.Minute, want: []string{missing}},
+			{at: 6 * time.Minute},
+			{at: 7 * time.Minute, doors: both},
+			{at: 8 * time.Minute, doors: garageOnly},
+			{at: 11 * time.Minute},
+			{at: 12 * time.Minute, want: []string{missing}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runPollSteps(t, Config{OpenThreshold: 30 * time.Minute, MissingAfter: tt.missingAfter}, tt.steps)
+		})
+	}
+}
+
+// fixedPorter reports the same doors on every poll, however malformed.
+type fixedPorter map[string]*client.DoorState
+
+func (p fixedPorter) List() (map[string]*client.DoorState, error) { return p, nil }
+
+func TestMalformedDoorsSkipped(t *testing.T) {
+	porter := fixedPorter{
+		"garage": {State: true, LastStateChangeTimestamp: pollStart.Add(-time.Hour)},
+		"  ":     {State: true, LastStateChangeTimestamp: pollStart.Add(-time.Hour)},
+		"shed":   nil,
+		"gate":   {State: true},
+	}
+	rec := &recordingNotifier{}
+	c := &controller{client: porter}
+	app := NewApp(Config{OpenThreshold: 30 * time.Minute, PollInterval: time.Minute, TimeFormat: time.Kitchen}, []*controller{c}, rec, nil)
+	app.clock = newFakeClock(pollStart)
+	m := newMonitor(app)
+
+	if err := m.poll(context.Background()); err != nil {
+		t.Fatalf("poll failed over malformed doors: %v", err)
+	}
+	if got := rec.sent(); len(got) != 1 || !strings.Contains(got[0], "garage has been open for 1 hour") {
+		t.Fatalf("sent %q, want only the garage alert", got)
+	}
+	if views := app.latestDoors.list(); len(views) != 1 || views[0].Name != "garage" {
+		t.Errorf("doors %+v, want only garage", views)
+	}
+	if len(c.malformed) != 3 {
+		t.Errorf("malformed doors %v, want three", c.malformed)
+	}
+
+	// Once gate's state is usable it is monitored like any other door.
+	porter["gate"] = &client.DoorState{State: true, LastStateChangeTimestamp: pollStart.Add(-time.Hour)}
+	if err := m.poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.sent(); len(got) != 2 || !strings.Contains(got[1], "gate has been open for 1 hour") {
+		t.Fatalf("sent %q, want a gate alert after the garage one", got)
+	}
+	if c.malformed["gate"] {
+		t.Error("gate still marked malformed")
+	}
+}
+
+func TestLifecycleMessages(t *testing.T) {
+	app := NewApp(Config{TimeFormat: time.Kitchen}, nil, nil, nil)
+	app.clock = newFakeClock(pollStart)
+
+	// The defaults keep the wording from before they could be overridden.
+	defaults := map[int]string{
+		MsgMonitorStarting: "[8:00AM] Porter notice: Door monitor started.",
+		MsgMonitorDying:    "[8:00AM] Porter notice: Door monitor is stopping.",
+		MsgMonitorError:    "[8:00AM] Porter notice: I'm having trouble reaching the door controller. The network might be offline, or the controller may need to be rebooted. I won't send any more messages until I can reach it.",
+		MsgMonitorRecover:  "[8:00AM] Porter notice: The garage door controller is back online! Status updates will resume.",
+	}
+	for msgType, want := range defaults {
+		if got := app.genMsg(msgType); got != want {
+			t.Errorf("genMsg(%s) = %q, want %q", eventName(msgType), got, want)
+		}
+	}
+
+	// -msgerror replaces the message sent when the controller can't be
+	// reached.
+	orig := msgTemplates[MsgMonitorError]
+	t.Cleanup(func() { msgTemplates[MsgMonitorError] = orig })
+	fs := flag.NewFlagSet("reporter", flag.ContinueOnError)
+	o := registerFlags(fs)
+	if err := fs.Parse([]string{"-slackwebhook", "https://hooks.example.com/x", "-msgerror", "Porter down at {{.Time}}"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buildApp(o); err != nil {
+		t.Fatal(err)
+	}
+
+	runPollSteps(t, Config{OpenThreshold: 30 * time.Minute}, []pollStep{
+		{at: 0, doors: []stubDoor{{name: "garage"}}},
+		{at: time.Minute, err: errors.New("connection refused"), want: []string{"Porter down at 8:01AM"}},
+		{at: 2 * time.Minute, err: errors.New("connection refused")},
+		{at: 10 * time.Minute, want: []string{"[8:10AM] Porter notice: The garage door controller is back online!"}},
+	})
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// MQTTNotifier publishes each notification as a JSON event to an MQTT broker,
+// e.g. for Home Assistant, along with every door opening and closing. It
+// speaks just enough MQTT 3.1.1 to connect, publish one message and
+// disconnect.
+type MQTTNotifier struct {
+	// Broker is host:port, optionally prefixed with tcp:// or, for TLS,
+	// ssl:// or mqtts://.
+	Broker   string
+	Topic    string
+	ClientID string
+	Username string
+	Password string
+	QoS      byte // 0 or 1
+	Retain   bool
+}
+
+type mqttEvent struct {
+	Door     string    `json:"door,omitempty"`
+	State    string    `json:"state,omitempty"`
+	Event    string    `json:"event"`
+	Duration int64     `json:"duration,omitempty"` // seconds
+	Time     time.Time `json:"ts"`
+	Message  string    `json:"message,omitempty"`
+}
+
+const (
+	mqttConnect    = 0x10
+	mqttConnAck    = 0x20
+	mqttPublish    = 0x30
+	mqttPubAck     = 0x40
+	mqttDisconnect = 0xe0
+)
+
+func (m *MQTTNotifier) Send(ctx context.Context, msg string) error {
+	ev := mqttEvent{Event: "message", Time: time.Now().UTC(), Message: msg}
+	if e, ok := eventFromContext(ctx); ok {
+		ev.Door = e.DoorName
+		ev.Event = eventName(e.Type)
+		ev.Duration = int64(e.Duration.Seconds())
+	}
+	return m.publishEvent(ctx, ev)
+}
+
+// DoorChanged publishes a door's new state. Unlike notifications, these are
+// never filtered, so the topic can be used to track every door.
+func (m *MQTTNotifier) DoorChanged(ctx context.Context, change DoorChange) error {
+	ev := mqttEvent{Door: change.Door, State: "closed", Event: "state", Time: change.At.UTC()}
+	if change.Open {
+		ev.State = "open"
+	}
+	return m.publishEvent(ctx, ev)
+}
+
+func (m *MQTTNotifier) publishEvent(ctx context.Context, ev mqttEvent) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	conn, err := m.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("connecting to mqtt broker: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	r := bufio.NewReader(conn)
+	if err := m.connect(conn, r); err != nil {
+		return err
+	}
+	if err := m.publish(conn, r, payload); err != nil {
+		return err
+	}
+
+	_, err = conn.Write([]byte{mqttDisconnect, 0})
+	return err
+}
+
+func (m *MQTTNotifier) dial(ctx context.Context) (net.Conn, error) {
+	addr := m.Broker
+	useTLS := false
+	for _, prefix := range []string{"ssl://", "mqtts://", "tls://"} {
+		if strings.HasPrefix(addr, prefix) {
+			addr = strings.TrimPrefix(addr, prefix)
+			useTLS = true
+		}
+	}
+	addr = strings.TrimPrefix(addr, "tcp://")
+	addr = strings.TrimPrefix(addr, "mqtt://")
+
+	if useTLS {
+		d := &tls.Dialer{}
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	d := &net.Dialer{}
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+func (m *MQTTNotifier) connect(w io.Writer, r *bufio.Reader) error {
+	clientID := m.ClientID
+	if clientID == "" {
+		// Brokers drop a connection when another arrives with the same
+		// client ID, so two reporters, or two publishes at once, mustn't
+		// share a default.
+		suffix := make([]byte, 4)
+		if _, err := rand.Read(suffix); err != nil {
+			return err
+		}
+		clientID = "porter-reporter-" + hex.EncodeToString(suffix)
+	}
+
+	var flags byte = 0x02 // clean session
+	body := mqttString(nil, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+	if m.Username != "" {
+		flags |= 0x80
+	}
+	if m.Password != "" {
+		flags |= 0x40
+	}
+	body = append(body, flags, 0, 60) // keep alive 60s
+	body = mqttString(body, clientID)
+	if m.Username != "" {
+		body = mqttString(body, m.Username)
+	}
+	if m.Password != "" {
+		body = mqttString(body, m.Password)
+	}
+
+	if err := mqttWritePacket(w, mqttConnect, body); err != nil {
+		return fmt.Errorf("sending mqtt connect: %w", err)
+	}
+
+	packetType, ack, err := mqttReadPacket(r)
+	if err != nil {
+		return fmt.Errorf("reading mqtt connack: %w", err)
+	}
+	if packetType != mqttConnAck || len(ack) != 2 {
+		return errors.New("mqtt broker sent an unexpected reply to connect")
+	}
+	if ack[1] != 0 {
+		return fmt.Errorf("mqtt broker refused connection (code %d)", ack[1])
+	}
+	return nil
+}
+
+func (m *MQTTNotifier) publish(w io.Writer, r *bufio.Reader, payload []byte) error {
+	header := byte(mqttPublish) | m.QoS<<1
+	if m.Retain {
+		header |= 0x01
+	}
+
+	const packetID = 1
+	body := mqttString(nil, m.Topic)
+	if m.QoS > 0 {
+		body = binary.BigEndian.AppendUint16(body, packetID)
+	}
+	body = append(body, payload...)
+
+	if err := mqttWritePacket(w, header, body); err != nil {
+		return fmt.Errorf("sending mqtt publish: %w", err)
+	}
+	if m.QoS == 0 {
+		return nil
+	}
+
+	packetType, ack, err := mqttReadPacket(r)
+	if err != nil {
+		return fmt.Errorf("reading mqtt puback: %w", err)
+	}
+	if packetType != mqttPubAck || len(ack) != 2 || binary.BigEndian.Uint16(ack) != packetID {
+		return errors.New("mqtt broker did not acknowledge publish")
+	}
+	return nil
+}
+
+func mqttString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func mqttWritePacket(w io.Writer, header byte, body []byte) error {
+	packet := []byte{header}
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if n == 0 {
+			break
+		}
+	}
+	packet = append(packet, body...)
+
+	_, err := w.Write(packet)
+	return err
+}
+
+// mqttReadPacket reads one packet, returning its type (the upper four bits of
+// the header) and body.
+func mqttReadPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	n, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed mqtt packet length")
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header & 0xf0, body, nil
+}
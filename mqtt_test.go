@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestMQTTRemainingLength(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{321, []byte{0xc1, 0x02}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+		{2097151, []byte{0xff, 0xff, 0x7f}},
+		{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		body := bytes.Repeat([]byte{'x'}, tt.n)
+		if err := mqttWritePacket(&buf, mqttPublish, body); err != nil {
+			t.Fatal(err)
+		}
+		packet := buf.Bytes()
+		if got := packet[1 : 1+len(tt.want)]; packet[0] != mqttPublish || !bytes.Equal(got, tt.want) {
+			t.Errorf("length %d encoded as % x, want % x", tt.n, got, tt.want)
+		}
+
+		packetType, gotBody, err := mqttReadPacket(bufio.NewReader(&buf))
+		if err != nil || packetType != mqttPublish || len(gotBody) != tt.n {
+			t.Errorf("reading back a %d byte body: type %#x, %d bytes, %v", tt.n, packetType, len(gotBody), err)
+		}
+	}
+}
+
+func TestMQTTReadPacketMalformedLength(t *testing.T) {
+	r := bufio.NewReader(bytes.NewReader([]byte{mqttPublish, 0x80, 0x80, 0x80, 0x80, 0x01}))
+	if _, _, err := mqttReadPacket(r); err == nil {
+		t.Error("accepted a five byte remaining length")
+	}
+}
+
+func TestMQTTPublish(t *testing.T) {
+	topic := append([]byte{0x00, 0x0c}, "porter/doors"...)
+
+	tests := []struct {
+		name   string
+		qos    byte
+		retain bool
+		reply  []byte // what the broker sends back
+		want   []byte
+	}{
+		{"qos 0", 0, false, nil,
+			append(append([]byte{0x30, 16}, topic...), `{}`...)},
+		{"qos 0 retained", 0, true, nil,
+			append(append([]byte{0x31, 16}, topic...), `{}`...)},
+		{"qos 1", 1, false, []byte{mqttPubAck, 0x02, 0x00, 0x01},
+			append(append(append([]byte{0x32, 18}, topic...), 0x00, 0x01), `{}`...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &MQTTNotifier{Topic: "porter/doors", QoS: tt.qos, Retain: tt.retain}
+			var out bytes.Buffer
+			if err := m.publish(&out, bufio.NewReader(bytes.NewReader(tt.reply)), []byte(`{}`)); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), tt.want) {
+				t.Errorf("publish wrote % x, want % x", out.Bytes(), tt.want)
+			}
+		})
+	}
+
+	m := &MQTTNotifier{Topic: "porter/doors", QoS: 1}
+	wrongID := []byte{mqttPubAck, 0x02, 0x00, 0x02}
+	if err := m.publish(&bytes.Buffer{}, bufio.NewReader(bytes.NewReader(wrongID)), []byte(`{}`)); err == nil {
+		t.Error("accepted a puback for another packet")
+	}
+}
+
+// mqttClientID returns the client ID m connects with.
+func mqttClientID(t *testing.T, m *MQTTNotifier) string {
+	t.Helper()
+	var out bytes.Buffer
+	connAck := bufio.NewReader(bytes.NewReader([]byte{mqttConnAck, 0x02, 0x00, 0x00}))
+	if err := m.connect(&out, connAck); err != nil {
+		t.Fatal(err)
+	}
+
+	_, body, err := mqttReadPacket(bufio.NewReader(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Protocol name, level, flags and keep alive come first.
+	body = body[10:]
+	return string(body[2 : 2+binary.BigEndian.Uint16(body)])
+}
+
+func TestMQTTClientID(t *testing.T) {
+	if got := mqttClientID(t, &MQTTNotifier{ClientID: "garage-pi"}); got != "garage-pi" {
+		t.Errorf("configured client ID sent as %q", got)
+	}
+
+	first := mqttClientID(t, &MQTTNotifier{})
+	second := mqttClientID(t, &MQTTNotifier{})
+	if !strings.HasPrefix(first, "porter-reporter-") || first == second {
+		t.Errorf("default client IDs %q and %q, want distinct porter-reporter- IDs", first, second)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// MultiNotifier fans a message out to every wrapped notifier concurrently.
+type MultiNotifier struct {
+	Notifiers []Notifier
+}
+
+func (m *MultiNotifier) Send(ctx context.Context, msg string) error {
+	wg := &sync.WaitGroup{}
+	errs := make([]error, len(m.Notifiers))
+	for i, n := range m.Notifiers {
+		wg.Add(1)
+		go (func(wg *sync.WaitGroup, i int, n Notifier) {
+			errs[i] = n.Send(ctx, msg)
+			wg.Done()
+		})(wg, i, n)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// FallbackNotifier tries each wrapped notifier in order, stopping at the first
+// one that succeeds.
+type FallbackNotifier struct {
+	Notifiers []Notifier
+}
+
+func (f *FallbackNotifier) Send(ctx context.Context, msg string) error {
+	var err error
+	for _, n := range f.Notifiers {
+		if err = n.Send(ctx, msg); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
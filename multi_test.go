@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedNotifier fails or succeeds in turn as errs says; once errs runs
+// out, sends succeed.
+type scriptedNotifier struct {
+	mu    sync.Mutex
+	errs  []error
+	calls int
+}
+
+func (s *scriptedNotifier) Send(ctx context.Context, msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls <= len(s.errs) {
+		return s.errs[s.calls-1]
+	}
+	return nil
+}
+
+func (s *scriptedNotifier) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// slowNotifier records a send only after a delay, to catch a fan-out that
+// returns before its channels finish.
+type slowNotifier struct {
+	scriptedNotifier
+	delay time.Duration
+}
+
+func (s *slowNotifier) Send(ctx context.Context, msg string) error {
+	time.Sleep(s.delay)
+	return s.scriptedNotifier.Send(ctx, msg)
+}
+
+func TestMultiNotifier(t *testing.T) {
+	failure := errors.New("down")
+	channels := []*slowNotifier{
+		{delay: 20 * time.Millisecond},
+		{delay: 10 * time.Millisecond, scriptedNotifier: scriptedNotifier{errs: []error{failure}}},
+		{},
+	}
+	var notifiers []Notifier
+	for _, c := range channels {
+		notifiers = append(notifiers, c)
+	}
+
+	err := (&MultiNotifier{Notifiers: notifiers}).Send(context.Background(), "hello")
+	if !errors.Is(err, failure) {
+		t.Errorf("Send() error = %v, want it to include %v", err, failure)
+	}
+	for i, c := range channels {
+		if got := c.count(); got != 1 {
+			t.Errorf("channel %d sent %d times before Send returned, want 1", i, got)
+		}
+	}
+}
+
+func TestFallbackNotifier(t *testing.T) {
+	failure := errors.New("down")
+	tests := []struct {
+		name      string
+		errs      [][]error
+		wantCalls []int
+		wantErr   bool
+	}{
+		{"first succeeds", [][]error{nil, nil}, []int{1, 0}, false},
+		{"falls back", [][]error{{failure}, nil}, []int{1, 1}, false},
+		{"all fail", [][]error{{failure}, {failure}}, []int{1, 1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var channels []*scriptedNotifier
+			var notifiers []Notifier
+			for _, errs := range tt.errs {
+				c := &scriptedNotifier{errs: errs}
+				channels = append(channels, c)
+				notifiers = append(notifiers, c)
+			}
+
+			err := (&FallbackNotifier{Notifiers: notifiers}).Send(context.Background(), "hello")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, want error %v", err, tt.wantErr)
+			}
+			for i, c := range channels {
+				if got := c.count(); got != tt.wantCalls[i] {
+					t.Errorf("channel %d sent %d times, want %d", i, got, tt.wantCalls[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+type Notifier interface {
+	Send(ctx context.Context, msg string) error
+}
+
+// DoorObserver is told of every door opening and closing the monitor sees,
+// whatever the notification filters, quiet hours and mutes let through. It
+// suits channels that track door state rather than relay messages.
+type DoorObserver interface {
+	DoorChanged(ctx context.Context, change DoorChange) error
+}
+
+// DoorChange is a door opening or closing.
+type DoorChange struct {
+	Door string
+	Open bool
+	At   time.Time
+
+	// Alerted is set on a close when an open notification was sent for
+	// the open event it ends.
+	Alerted bool
+}
+
+// Event describes what a notification is about, so that notifiers can format
+// messages beyond the plain text produced by genMsg.
+type Event struct {
+	Type      int
+	DoorName  string
+	Duration  time.Duration
+	Escalated bool
+	Severity  Severity
+
+	// Changed is when the door state this event is about began, if known.
+	Changed time.Time
+
+	// Doors lists the doors covered by a batched notification, which may be
+	// sent in several parts.
+	Doors []string
+	Part  int
+
+	// Localized holds the message rendered in each contact language that
+	// has a template for it.
+	Localized map[string]string
+}
+
+type eventKey struct{}
+
+func withEvent(ctx context.Context, ev Event) context.Context {
+	return context.WithValue(ctx, eventKey{}, ev)
+}
+
+func eventFromContext(ctx context.Context) (Event, bool) {
+	ev, ok := ctx.Value(eventKey{}).(Event)
+	return ev, ok
+}
+
+// msgTypeByName returns the message type eventName gives name to.
+func msgTypeByName(name string) (int, bool) {
+	for msgType := 0; eventName(msgType) != "unknown"; msgType++ {
+		if eventName(msgType) == name {
+			return msgType, true
+		}
+	}
+	return 0, false
+}
+
+func eventName(msgType int) string {
+	switch msgType {
+	case MsgStateChangeOpen:
+		return "open"
+	case MsgStateChangeClosed:
+		return "closed"
+	case MsgMonitorDying:
+		return "stopping"
+	case MsgMonitorStarting:
+		return "starting"
+	case MsgMonitorError:
+		return "error"
+	case MsgMonitorRecover:
+		return "recover"
+	case MsgTest:
+		return "test"
+	case MsgClockSkew:
+		return "clockskew"
+	case MsgDoorOpened:
+		return "opened"
+	case MsgBatchOpen:
+		return "open_batch"
+	case MsgDigest:
+		return "digest"
+	case MsgFlapping:
+		return "flapping"
+	case MsgSMSBudget:
+		return "sms_budget"
+	case MsgDoorMissing:
+		return "missing"
+	case MsgDailyOpenLimit:
+		return "daily_open"
+	case MsgManual:
+		return "manual"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NtfyNotifier posts messages to an ntfy topic.
+type NtfyNotifier struct {
+	URL   string // topic URL, e.g. https://ntfy.sh/my-garage
+	Token string // bearer token for protected topics
+}
+
+func (n *NtfyNotifier) Send(ctx context.Context, msg string) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", n.URL, strings.NewReader(msg))
+	if err != nil {
+		return fmt.Errorf("building ntfy request: %w", err)
+	}
+	req.Header.Set("Title", "Porter")
+	if ev, ok := eventFromContext(ctx); ok {
+		req.Header.Set("Priority", ntfyPriority(ev.Severity))
+		req.Header.Set("Tags", ntfyTags(ev))
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	res, err := postWithRetry(ctx, httpClient, req, webhookRetryPolicy)
+	if err != nil {
+		return fmt.Errorf("sending to ntfy: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return &httpStatusError{Service: "ntfy", Status: res.StatusCode, Detail: strings.TrimSpace(string(body))}
+	}
+
+	return nil
+}
+
+func ntfyPriority(s Severity) string {
+	switch s {
+	case SeverityCritical:
+		return "urgent"
+	case SeverityWarning:
+		return "high"
+	default:
+		return "default"
+	}
+}
+
+// ntfyTags picks an emoji tag for the event, followed by the event name.
+func ntfyTags(ev Event) string {
+	emoji := "information_source"
+	switch {
+	case ev.Severity == SeverityCritical:
+		emoji = "rotating_light"
+	case ev.Type == MsgStateChangeClosed || ev.Type == MsgMonitorRecover:
+		emoji = "white_check_mark"
+	case ev.Severity == SeverityWarning:
+		emoji = "warning"
+	}
+	return emoji + "," + eventName(ev.Type)
+}
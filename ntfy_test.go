@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNtfyNotifier(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       string
+		ev          *Event
+		status      int
+		wantHeaders map[string]string
+		wantErr     string
+	}{
+		{"plain", "", nil, http.StatusOK,
+			map[string]string{"Title": "Porter", "Priority": "", "Tags": "", "Authorization": ""}, ""},
+		{"open alert", "tk_abc", &Event{Type: MsgStateChangeOpen, Severity: SeverityWarning}, http.StatusOK,
+			map[string]string{"Priority": "high", "Tags": "warning,open", "Authorization": "Bearer tk_abc"}, ""},
+		{"closed", "", &Event{Type: MsgStateChangeClosed, Severity: SeverityInfo}, http.StatusOK,
+			map[string]string{"Priority": "default", "Tags": "white_check_mark,closed"}, ""},
+		{"critical", "", &Event{Type: MsgMonitorError, Severity: SeverityCritical}, http.StatusOK,
+			map[string]string{"Priority": "urgent", "Tags": "rotating_light,error"}, ""},
+		{"forbidden", "wrong", nil, http.StatusForbidden, nil,
+			"ntfy returned HTTP 403: forbidden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header http.Header
+			var body string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/my-garage" {
+					t.Errorf("request to %s", r.URL.Path)
+				}
+				b, _ := io.ReadAll(r.Body)
+				header, body = r.Header, string(b)
+				w.WriteHeader(tt.status)
+				if tt.status != http.StatusOK {
+					w.Write([]byte("forbidden\n"))
+				}
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			if tt.ev != nil {
+				ctx = withEvent(ctx, *tt.ev)
+			}
+			err := (&NtfyNotifier{URL: srv.URL + "/my-garage", Token: tt.token}).Send(ctx, "Garage is open.")
+
+			var statusErr *httpStatusError
+			if tt.wantErr != "" {
+				if !errors.As(err, &statusErr) || err.Error() != tt.wantErr {
+					t.Errorf("Send() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if body != "Garage is open." {
+				t.Errorf("body %q", body)
+			}
+			for k, want := range tt.wantHeaders {
+				if got := header.Get(k); got != want {
+					t.Errorf("%s header %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
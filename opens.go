@@ -0,0 +1,117 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// openCounter tracks how many times, and for how long, each door has been
+// open since local midnight.
+type openCounter struct {
+	mu     sync.Mutex
+	day    time.Time
+	counts map[string]int
+	last   map[string]time.Time // latest opening counted, by door
+
+	// Time each door spent open today in openings that have ended, and
+	// the doors already alerted about for -maxdailyopen.
+	openFor map[string]time.Duration
+	alerted map[string]bool
+}
+
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
+
+// record counts an opening at the given time. An opening that has already
+// been counted, e.g. one replayed from the event store, is ignored.
+func (c *openCounter) record(door string, at time.Time, loc *time.Location) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rollover(at, loc)
+	if !at.After(c.last[door]) || at.Before(c.day) {
+		return
+	}
+	c.last[door] = at
+	c.counts[door]++
+}
+
+// recordClose adds the part of an opening that fell on the current day to
+// the door's open time.
+func (c *openCounter) recordClose(door string, opened, closed time.Time, loc *time.Location) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rollover(closed, loc)
+	if opened.Before(c.day) {
+		opened = c.day
+	}
+	if closed.After(opened) {
+		c.openFor[door] += closed.Sub(opened)
+	}
+}
+
+// overLimit returns how long door has been open today, counting an opening
+// still in progress since openSince, and reports whether that has just gone
+// past limit. It reports true at most once per door per day.
+func (c *openCounter) overLimit(door string, openSince, now time.Time, limit time.Duration, loc *time.Location) (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rollover(now, loc)
+	if openSince.Before(c.day) {
+		openSince = c.day
+	}
+	total := c.openFor[door]
+	if now.After(openSince) {
+		total += now.Sub(openSince)
+	}
+	if total < limit || c.alerted[door] {
+		return total, false
+	}
+	c.alerted[door] = true
+	return total, true
+}
+
+// today returns how many times door has opened since midnight before now.
+func (c *openCounter) today(door string, now time.Time, loc *time.Location) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rollover(now, loc)
+	return c.counts[door]
+}
+
+func (c *openCounter) rollover(now time.Time, loc *time.Location) {
+	day := startOfDay(now, loc)
+	if c.counts == nil || day.After(c.day) {
+		c.day = day
+		c.counts = make(map[string]int)
+		c.openFor = make(map[string]time.Duration)
+		c.alerted = make(map[string]bool)
+		if c.last == nil {
+			c.last = make(map[string]time.Time)
+		}
+	}
+}
+
+// loadOpenCounts counts today's openings from the event store, if there is
+// one, so counts survive a restart.
+func (a *App) loadOpenCounts() {
+	if a.events == nil {
+		return
+	}
+
+	now := a.clock.Now()
+	records, err := a.events.Query(EventFilter{Kind: "open", Since: startOfDay(now, a.cfg.TimeLocation)})
+	if err != nil {
+		slog.Warn("couldn't load today's door openings", "error", err)
+		return
+	}
+	for _, r := range records {
+		a.opens.record(r.Door, r.Time, a.cfg.TimeLocation)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOrdinal(t *testing.T) {
+	tests := map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th", 21: "21st", 102: "102nd", 111: "111th"}
+	for n, want := range tests {
+		if got := ordinal(n); got != want {
+			t.Errorf("ordinal(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestOpenCounter(t *testing.T) {
+	loc := time.FixedZone("EST", -5*60*60)
+	// 8:00 UTC is 3:00 local, so local midnight is at 5:00 UTC.
+	at := func(d time.Duration) time.Time { return pollStart.Add(d) }
+	c := &openCounter{}
+
+	c.record("garage", at(0), loc)
+	c.record("garage", at(time.Hour), loc)
+	c.record("garage", at(time.Hour), loc) // counted already
+	c.record("shed", at(2*time.Hour), loc)
+
+	tests := []struct {
+		door string
+		now  time.Duration
+		want int
+	}{
+		{"garage", 3 * time.Hour, 2},
+		{"shed", 3 * time.Hour, 1},
+		{"gate", 3 * time.Hour, 0},
+		{"garage", 20*time.Hour + 59*time.Minute, 2},
+		{"garage", 21 * time.Hour, 0}, // local midnight
+	}
+	for _, tt := range tests {
+		if got := c.today(tt.door, at(tt.now), loc); got != tt.want {
+			t.Errorf("today(%s) at %v = %d, want %d", tt.door, tt.now, got, tt.want)
+		}
+	}
+
+	// An opening from before midnight isn't counted on the new day.
+	c.record("garage", at(20*time.Hour), loc)
+	if got := c.today("garage", at(21*time.Hour), loc); got != 0 {
+		t.Errorf("yesterday's opening counted today: %d", got)
+	}
+}
+
+func TestLoadOpenCounts(t *testing.T) {
+	store := NewFileEventStore(filepath.Join(t.TempDir(), "events.jsonl"))
+	for _, r := range []EventRecord{
+		{Time: pollStart.Add(-9 * time.Hour), Kind: "open", Door: "garage"}, // yesterday
+		{Time: pollStart.Add(-2 * time.Hour), Kind: "open", Door: "garage"},
+		{Time: pollStart.Add(-time.Hour), Kind: "close", Door: "garage"},
+		{Time: pollStart.Add(-time.Minute), Kind: "open", Door: "garage"},
+	} {
+		if err := store.Record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := NewApp(Config{TimeLocation: time.UTC}, nil, nil, nil)
+	app.clock = newFakeClock(pollStart)
+	app.events = store
+	app.loadOpenCounts()
+
+	if got := app.opens.today("garage", pollStart, time.UTC); got != 2 {
+		t.Errorf("loaded %d openings for today, want 2", got)
+	}
+
+	// The monitor counting an opening already loaded doesn't count it twice.
+	app.opens.record("garage", pollStart.Add(-time.Minute), time.UTC)
+	if got := app.opens.today("garage", pollStart, time.UTC); got != 2 {
+		t.Errorf("%d openings after recording a loaded one again, want 2", got)
+	}
+}
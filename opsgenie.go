@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hako/durafmt"
+)
+
+const opsgenieAPIURL = "https://api.opsgenie.com"
+
+// opsgenieMaxMessage is the longest alert message Opsgenie accepts; the full
+// text goes in the description.
+const opsgenieMaxMessage = 130
+
+// OpsgenieNotifier creates an Opsgenie alert when a door has been left open
+// and closes it when the door closes, using the Alert API. Other messages are
+// ignored. Closes are observed directly from the monitor, so an alert is
+// closed even when the close notice itself is filtered out.
+type OpsgenieNotifier struct {
+	APIKey string
+
+	// URL overrides the API base URL, e.g. https://api.eu.opsgenie.com.
+	URL string
+
+	mu     sync.Mutex
+	opened map[string]bool
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Entity      string            `json:"entity,omitempty"`
+	Source      string            `json:"source"`
+	Priority    string            `json:"priority"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+type opsgenieClose struct {
+	Source string `json:"source"`
+	Note   string `json:"note,omitempty"`
+}
+
+func (o *OpsgenieNotifier) Send(ctx context.Context, msg string) error {
+	ev, ok := eventFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	switch ev.Type {
+	case MsgStateChangeOpen:
+		return o.create(ctx, ev, ev.DoorName, msg)
+	case MsgBatchOpen:
+		var errs []error
+		for _, door := range ev.Doors {
+			errs = append(errs, o.create(ctx, ev, door, msg))
+		}
+		return errors.Join(errs...)
+	default:
+		return nil
+	}
+}
+
+// DoorChanged closes a door's alert when it closes, and lets the next open
+// event create a new one.
+func (o *OpsgenieNotifier) DoorChanged(ctx context.Context, change DoorChange) error {
+	alias := opsgenieAlias(change.Door)
+
+	o.mu.Lock()
+	opened := o.opened[alias]
+	if change.Open {
+		delete(o.opened, alias)
+	}
+	o.mu.Unlock()
+
+	if change.Open || !(opened || change.Alerted) {
+		return nil
+	}
+	return o.close(ctx, change.Door, "Closed at "+change.At.Format(time.RFC3339))
+}
+
+// opsgenieAlias identifies a door's alert; it is the controller-qualified
+// door name, so doors on different controllers get separate alerts.
+func opsgenieAlias(doorName string) string {
+	return "porter/" + doorName
+}
+
+func opsgeniePriority(ev Event) string {
+	switch {
+	case ev.Severity == SeverityCritical:
+		return "P1"
+	case ev.Escalated:
+		return "P2"
+	default:
+		return "P3"
+	}
+}
+
+// create opens an alert for a door, once per open event. Opsgenie
+// deduplicates on the alias too, so a repeat after a restart only bumps the
+// existing alert's count.
+func (o *OpsgenieNotifier) create(ctx context.Context, ev Event, doorName, msg string) error {
+	alias := opsgenieAlias(doorName)
+
+	o.mu.Lock()
+	if o.opened[alias] {
+		o.mu.Unlock()
+		return nil
+	}
+	o.mu.Unlock()
+
+	summary := msg
+	if r := []rune(msg); len(r) > opsgenieMaxMessage {
+		summary = string(r[:opsgenieMaxMessage-3]) + "..."
+	}
+	details := map[string]string{"door": doorName}
+	if label, door, ok := strings.Cut(doorName, "/"); ok {
+		details["controller"], details["door"] = label, door
+	}
+	if ev.Type == MsgStateChangeOpen {
+		details["open_for"] = durafmt.ParseShort(ev.Duration).String()
+		if !ev.Changed.IsZero() {
+			details["opened_at"] = ev.Changed.Format(time.RFC3339)
+		}
+	}
+
+	err := o.post(ctx, "/v2/alerts", &opsgenieAlert{
+		Message:     summary,
+		Alias:       alias,
+		Description: msg,
+		Entity:      doorName,
+		Source:      "porter-reporter",
+		Priority:    opsgeniePriority(ev),
+		Tags:        []string{"porter", eventName(ev.Type)},
+		Details:     details,
+	})
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.opened == nil {
+		o.opened = make(map[string]bool)
+	}
+	o.opened[alias] = true
+	return nil
+}
+
+func (o *OpsgenieNotifier) close(ctx context.Context, doorName, msg string) error {
+	alias := opsgenieAlias(doorName)
+	path := "/v2/alerts/" + url.PathEscape(alias) + "/close?identifierType=alias"
+	if err := o.post(ctx, path, &opsgenieClose{Source: "porter-reporter", Note: msg}); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.opened, alias)
+	return nil
+}
+
+func (o *OpsgenieNotifier) post(ctx context.Context, path string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	base := o.URL
+	if base == "" {
+		base = opsgenieAPIURL
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(base, "/")+path, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("building opsgenie request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", "GenieKey "+o.APIKey)
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	res, err := postWithRetry(ctx, httpClient, req, webhookRetryPolicy)
+	if err != nil {
+		return fmt.Errorf("sending to opsgenie: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return &httpStatusError{Service: "opsgenie", Status: res.StatusCode, Detail: strings.TrimSpace(string(body))}
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeOpsgenie records the requests made to it as "create alias priority" or
+// "close alias".
+type fakeOpsgenie struct {
+	mu       sync.Mutex
+	status   int
+	requests []string
+}
+
+func (f *fakeOpsgenie) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "GenieKey K3Y" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var record string
+	switch path := r.URL.EscapedPath(); {
+	case path == "/v2/alerts":
+		var alert opsgenieAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil || alert.Message == "" {
+			http.Error(w, "invalid alert", http.StatusBadRequest)
+			return
+		}
+		record = "create " + alert.Alias + " " + alert.Priority
+	case strings.HasPrefix(path, "/v2/alerts/") && strings.HasSuffix(path, "/close"):
+		if r.URL.Query().Get("identifierType") != "alias" {
+			http.Error(w, "not an alias", http.StatusBadRequest)
+			return
+		}
+		alias, _ := url.PathUnescape(strings.TrimSuffix(strings.TrimPrefix(path, "/v2/alerts/"), "/close"))
+		record = "close " + alias
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status != 0 {
+		http.Error(w, "invalid alert", f.status)
+		return
+	}
+	f.requests = append(f.requests, record)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (f *fakeOpsgenie) take() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	requests := f.requests
+	f.requests = nil
+	return requests
+}
+
+func TestOpsgenieNotifier(t *testing.T) {
+	fake := &fakeOpsgenie{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	og := &OpsgenieNotifier{APIKey: "K3Y", URL: srv.URL + "/"}
+
+	open := func(door string, severity Severity) func() error {
+		return func() error {
+			ev := Event{Type: MsgStateChangeOpen, DoorName: door, Severity: severity, Duration: 30 * time.Minute}
+			return og.Send(withEvent(context.Background(), ev), door+" has been open")
+		}
+	}
+	changed := func(door string, isOpen, alerted bool) func() error {
+		return func() error {
+			return og.DoorChanged(context.Background(), DoorChange{Door: door, Open: isOpen, At: time.Now(), Alerted: alerted})
+		}
+	}
+
+	steps := []struct {
+		name string
+		do   func() error
+		want []string
+	}{
+		{"open alert creates", open("garage", SeverityWarning), []string{"create porter/garage P3"}},
+		{"repeat doesn't create again", open("garage", SeverityWarning), nil},
+		{"other messages are ignored", func() error {
+			return og.Send(withEvent(context.Background(), Event{Type: MsgDoorOpened, DoorName: "garage"}), "opened")
+		}, nil},
+		{"messages without an event are ignored", func() error {
+			return og.Send(context.Background(), "hello")
+		}, nil},
+		{"close closes by alias", changed("garage", false, true), []string{"close porter/garage"}},
+		{"reopening", changed("garage", true, false), nil},
+		{"critical open event", open("garage", SeverityCritical), []string{"create porter/garage P1"}},
+		{"close closes it", changed("garage", false, false), []string{"close porter/garage"}},
+		{"unalerted close of a door without an alert", changed("shed", false, false), nil},
+		{"alerted close from before a restart", changed("home/shed", false, true), []string{"close porter/home/shed"}},
+		{"batch creates one per door", func() error {
+			ev := Event{Type: MsgBatchOpen, Doors: []string{"east/garage", "west/garage"}, Escalated: true}
+			return og.Send(withEvent(context.Background(), ev), "2 doors have been left open")
+		}, []string{"create porter/east/garage P2", "create porter/west/garage P2"}},
+	}
+
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := fake.take(); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: requested %q, want %q", step.name, got, step.want)
+		}
+	}
+}
+
+func TestOpsgenieRejected(t *testing.T) {
+	fake := &fakeOpsgenie{status: http.StatusUnprocessableEntity}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	og := &OpsgenieNotifier{APIKey: "K3Y", URL: srv.URL}
+	ctx := withEvent(context.Background(), Event{Type: MsgStateChangeOpen, DoorName: "garage"})
+
+	err := og.Send(ctx, "garage has been open")
+	var statusErr *httpStatusError
+	if !errors.As(err, &statusErr) || err.Error() != "opsgenie returned HTTP 422: invalid alert" {
+		t.Fatalf("Send() error = %v, want the API's rejection", err)
+	}
+
+	// A failed create isn't counted, so the next alert tries again.
+	fake.mu.Lock()
+	fake.status = 0
+	fake.mu.Unlock()
+	if err := og.Send(ctx, "garage has been open"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.take(); !reflect.DeepEqual(got, []string{"create porter/garage P3"}) {
+		t.Errorf("requested %q after the failure, want a create", got)
+	}
+}
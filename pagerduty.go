@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyNotifier opens a PagerDuty incident when a door has been left open
+// and resolves it when the door closes, using the Events API v2. Other
+// messages are ignored. Closes are observed directly from the monitor, so an
+// incident is resolved even when the close notice itself is filtered out.
+type PagerDutyNotifier struct {
+	RoutingKey string
+
+	// URL overrides the Events API endpoint.
+	URL string
+
+	mu        sync.Mutex
+	triggered map[string]bool
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary  string `json:"summary"`
+	Source   string `json:"source"`
+	Severity string `json:"severity"`
+}
+
+func (p *PagerDutyNotifier) Send(ctx context.Context, msg string) error {
+	ev, ok := eventFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	switch ev.Type {
+	case MsgStateChangeOpen:
+		return p.trigger(ctx, ev, ev.DoorName, msg)
+	case MsgBatchOpen:
+		var errs []error
+		for _, door := range ev.Doors {
+			errs = append(errs, p.trigger(ctx, ev, door, msg))
+		}
+		return errors.Join(errs...)
+	default:
+		return nil
+	}
+}
+
+// DoorChanged resolves a door's incident when it closes, and lets the next
+// open event trigger a new one.
+func (p *PagerDutyNotifier) DoorChanged(ctx context.Context, change DoorChange) error {
+	key := pagerDutyDedupKey(change.Door)
+
+	p.mu.Lock()
+	triggered := p.triggered[key]
+	if change.Open {
+		delete(p.triggered, key)
+	}
+	p.mu.Unlock()
+
+	if change.Open || !(triggered || change.Alerted) {
+		return nil
+	}
+	return p.resolve(ctx, change.Door)
+}
+
+func pagerDutyDedupKey(doorName string) string {
+	return "porter/" + doorName
+}
+
+// trigger opens an incident for a door, once per open event. PagerDuty
+// deduplicates on the key too, so a repeat after a restart is harmless.
+func (p *PagerDutyNotifier) trigger(ctx context.Context, ev Event, doorName, msg string) error {
+	key := pagerDutyDedupKey(doorName)
+
+	p.mu.Lock()
+	if p.triggered[key] {
+		p.mu.Unlock()
+		return nil
+	}
+	p.mu.Unlock()
+
+	severity := "warning"
+	if ev.Escalated || ev.Severity == SeverityCritical {
+		severity = "critical"
+	}
+
+	err := p.post(ctx, &pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    key,
+		Payload:     &pagerDutyPayload{Summary: msg, Source: "porter-reporter", Severity: severity},
+	})
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.triggered == nil {
+		p.triggered = make(map[string]bool)
+	}
+	p.triggered[key] = true
+	return nil
+}
+
+func (p *PagerDutyNotifier) resolve(ctx context.Context, doorName string) error {
+	key := pagerDutyDedupKey(doorName)
+	if err := p.post(ctx, &pagerDutyEvent{RoutingKey: p.RoutingKey, EventAction: "resolve", DedupKey: key}); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.triggered, key)
+	return nil
+}
+
+func (p *PagerDutyNotifier) post(ctx context.Context, event *pagerDutyEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	url := p.URL
+	if url == "" {
+		url = pagerDutyEventsURL
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("building pagerduty request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	res, err := postWithRetry(ctx, httpClient, req, webhookRetryPolicy)
+	if err != nil {
+		return fmt.Errorf("sending to pagerduty: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return &httpStatusError{Service: "pagerduty", Status: res.StatusCode, Detail: strings.TrimSpace(string(body))}
+	}
+
+	return nil
+}
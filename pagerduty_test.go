@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePagerDuty records the events posted to it as "action key severity".
+type fakePagerDuty struct {
+	mu     sync.Mutex
+	status int
+	events []string
+}
+
+func (f *fakePagerDuty) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var ev pagerDutyEvent
+	if err := json.NewDecoder(r.Body).Decode(&ev); err != nil || ev.RoutingKey != "R0UT1NG" {
+		http.Error(w, "invalid event", http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status != 0 {
+		http.Error(w, "invalid routing key", f.status)
+		return
+	}
+	record := ev.EventAction + " " + ev.DedupKey
+	if ev.Payload != nil {
+		record += " " + ev.Payload.Severity
+	}
+	f.events = append(f.events, record)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (f *fakePagerDuty) take() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	events := f.events
+	f.events = nil
+	return events
+}
+
+func TestPagerDutyNotifier(t *testing.T) {
+	fake := &fakePagerDuty{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	pd := &PagerDutyNotifier{RoutingKey: "R0UT1NG", URL: srv.URL}
+
+	open := func(door string, escalated bool) func() error {
+		return func() error {
+			ev := Event{Type: MsgStateChangeOpen, DoorName: door, Severity: SeverityWarning, Escalated: escalated}
+			return pd.Send(withEvent(context.Background(), ev), door+" has been open")
+		}
+	}
+	changed := func(door string, isOpen, alerted bool) func() error {
+		return func() error {
+			return pd.DoorChanged(context.Background(), DoorChange{Door: door, Open: isOpen, At: time.Now(), Alerted: alerted})
+		}
+	}
+
+	steps := []struct {
+		name string
+		do   func() error
+		want []string
+	}{
+		{"open alert triggers", open("garage", false), []string{"trigger porter/garage warning"}},
+		{"repeat doesn't trigger again", open("garage", false), nil},
+		{"escalated repeat doesn't either", open("garage", true), nil},
+		{"other messages are ignored", func() error {
+			return pd.Send(withEvent(context.Background(), Event{Type: MsgDoorOpened, DoorName: "garage"}), "opened")
+		}, nil},
+		{"close resolves", changed("garage", false, true), []string{"resolve porter/garage"}},
+		{"reopening", changed("garage", true, false), nil},
+		{"new open event triggers", open("garage", true), []string{"trigger porter/garage critical"}},
+		{"close resolves it", changed("garage", false, false), []string{"resolve porter/garage"}},
+		{"unalerted close of an untriggered door", changed("shed", false, false), nil},
+		{"alerted close from before a restart", changed("shed", false, true), []string{"resolve porter/shed"}},
+		{"batch triggers each door", func() error {
+			ev := Event{Type: MsgBatchOpen, Doors: []string{"east/garage", "west/garage"}, Severity: SeverityWarning}
+			return pd.Send(withEvent(context.Background(), ev), "2 doors have been left open")
+		}, []string{"trigger porter/east/garage warning", "trigger porter/west/garage warning"}},
+	}
+
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := fake.take(); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: posted %q, want %q", step.name, got, step.want)
+		}
+	}
+}
+
+func TestPagerDutyRejected(t *testing.T) {
+	fake := &fakePagerDuty{status: http.StatusBadRequest}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	pd := &PagerDutyNotifier{RoutingKey: "R0UT1NG", URL: srv.URL}
+	ctx := withEvent(context.Background(), Event{Type: MsgStateChangeOpen, DoorName: "garage"})
+
+	err := pd.Send(ctx, "garage has been open")
+	var statusErr *httpStatusError
+	if !errors.As(err, &statusErr) || err.Error() != "pagerduty returned HTTP 400: invalid routing key" {
+		t.Fatalf("Send() error = %v, want the API's rejection", err)
+	}
+
+	// A failed trigger isn't counted, so the next alert tries again.
+	fake.mu.Lock()
+	fake.status = 0
+	fake.mu.Unlock()
+	if err := pd.Send(ctx, "garage has been open"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.take(); !reflect.DeepEqual(got, []string{"trigger porter/garage warning"}) {
+		t.Errorf("posted %q after the failure, want a trigger", got)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// regionCallingCodes maps the regions accepted by -defaultregion to their
+// country calling codes.
+var regionCallingCodes = map[string]string{
+	"US": "1", "CA": "1", "GB": "44", "IE": "353", "AU": "61", "NZ": "64",
+	"DE": "49", "FR": "33", "ES": "34", "IT": "39", "NL": "31", "BE": "32",
+	"CH": "41", "AT": "43", "SE": "46", "NO": "47", "DK": "45", "FI": "358",
+	"PL": "48", "PT": "351", "MX": "52", "BR": "55", "IN": "91", "JP": "81",
+	"ZA": "27",
+}
+
+// normalizePhone converts a phone number to E.164. Spaces and punctuation are
+// ignored, a leading 00 is treated as +, and numbers without a country code
+// are completed from region when one is given.
+func normalizePhone(number, region string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r == '+':
+			return r
+		case r == ' ', r == '-', r == '.', r == '(', r == ')':
+			return -1
+		default:
+			return 'x'
+		}
+	}, strings.TrimSpace(number))
+
+	if strings.HasPrefix(digits, "00") {
+		digits = "+" + digits[2:]
+	}
+
+	if !strings.HasPrefix(digits, "+") {
+		code, ok := regionCallingCodes[strings.ToUpper(region)]
+		if !ok {
+			return "", fmt.Errorf("phone number %q is not in E.164 format (e.g. +18005550199); add the country code or set -defaultregion", number)
+		}
+		switch {
+		case code == "1" && len(digits) == 11 && digits[0] == '1':
+			digits = "+" + digits
+		case code == "1":
+			digits = "+1" + digits
+		default:
+			digits = "+" + code + strings.TrimPrefix(digits, "0")
+		}
+	}
+
+	rest := digits[1:]
+	if strings.ContainsAny(rest, "+x") || len(rest) < 8 || len(rest) > 15 || rest[0] == '0' {
+		return "", fmt.Errorf("invalid phone number %q", number)
+	}
+	if strings.HasPrefix(rest, "1") && len(rest) != 11 {
+		return "", fmt.Errorf("invalid phone number %q: North American numbers have 10 digits after +1", number)
+	}
+	return digits, nil
+}
+
+// normalizePhones splits a comma-separated list of phone numbers and
+// normalizes each to E.164, ignoring empty entries. A list that isn't blank
+// but holds no numbers, such as ",", is an error.
+func normalizePhones(list, region string) ([]string, error) {
+	var numbers []string
+	for _, number := range splitList(list) {
+		n, err := normalizePhone(number, region)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, n)
+	}
+	if len(numbers) == 0 && strings.TrimSpace(list) != "" {
+		return nil, fmt.Errorf("phone number list %q contains no numbers", list)
+	}
+	return mergeRecipients(numbers), nil
+}
+
+// readRecipientsFile reads phone numbers from path, one per line. Blank lines
+// and lines starting with # are ignored.
+func readRecipientsFile(path, region string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading recipients file: %w", err)
+	}
+
+	var numbers []string
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		n, err := normalizePhone(line, region)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		numbers = append(numbers, n)
+	}
+	if len(numbers) == 0 {
+		return nil, fmt.Errorf("%s: no recipients", path)
+	}
+	return numbers, nil
+}
+
+// mergeRecipients combines recipient lists, dropping duplicates.
+func mergeRecipients(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, list := range lists {
+		for _, number := range list {
+			if !seen[number] {
+				seen[number] = true
+				merged = append(merged, number)
+			}
+		}
+	}
+	return merged
+}
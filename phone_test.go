@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		number  string
+		region  string
+		want    string
+		wantErr bool
+	}{
+		{"+18005550199", "", "+18005550199", false},
+		{" +1 (800) 555-0199 ", "", "+18005550199", false},
+		{"+44 20 7946 0958", "", "+442079460958", false},
+		{"0044 20 7946 0958", "", "+442079460958", false},
+		{"800.555.0199", "US", "+18005550199", false},
+		{"1 800 555 0199", "us", "+18005550199", false},
+		{"020 7946 0958", "GB", "+442079460958", false},
+		{"8005550199", "", "", true},   // no country code or region
+		{"8005550199", "XX", "", true}, // unknown region
+		{"+1800555019", "", "", true},  // too short for North America
+		{"+180055501999", "", "", true},
+		{"+4412", "", "", true},
+		{"+0445550199", "", "", true},
+		{"+1800555O199", "", "", true}, // letter O
+		{"+1800+5550199", "", "", true},
+		{"", "US", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizePhone(tt.number, tt.region)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizePhone(%q, %q) error = %v, want error %v", tt.number, tt.region, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizePhone(%q, %q) = %q, want %q", tt.number, tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizePhones(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"+18005550199, 800-555-0100", []string{"+18005550199", "+18005550100"}, false},
+		{"+18005550199,+1 800 555 0199", []string{"+18005550199"}, false},
+		{",", nil, true},
+		{"+18005550199,nope", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizePhones(tt.list, "US")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizePhones(%q) error = %v, want error %v", tt.list, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("normalizePhones(%q) = %q, want %q", tt.list, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// httpClientSetter is implemented by Porter clients whose HTTP client can be
+// replaced.
+type httpClientSetter interface {
+	SetHTTPClient(*http.Client)
+}
+
+// porterTransport identifies the reporter on every request to the Porter
+// API, so that controller logs show where polls come from, and adds the
+// bearer token if there is one.
+type porterTransport struct {
+	base        http.RoundTripper
+	userAgent   string
+	bearerToken string
+}
+
+func (t *porterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	if t.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+t.bearerToken)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// newPorterHTTPClient returns the HTTP client for a controller's requests. A
+// nil tlsConfig uses the system defaults, and an empty userAgent
+// porter-reporter/<version>.
+func newPorterHTTPClient(tlsConfig *tls.Config, bearerToken, userAgent string) *http.Client {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		base.TLSClientConfig = tlsConfig
+	}
+	if userAgent == "" {
+		userAgent = "porter-reporter/" + version
+	}
+	return &http.Client{Transport: &porterTransport{base: base, userAgent: userAgent, bearerToken: bearerToken}}
+}
+
+// porterTLSConfig builds the TLS settings for controllers served with a
+// private CA or requiring a client certificate. It returns nil if none of the
+// options are set.
+func porterTLSConfig(caFile, certFile, keyFile string, insecure bool) (*tls.Config, error) {
+	if caFile == "" && certFile == "" && keyFile == "" && !insecure {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{InsecureSkipVerify: insecure}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading -pcacert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("-pcacert %s contains no PEM certificates", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("-pclientcert and -pclientkey must be given together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading Porter client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
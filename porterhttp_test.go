@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPorterHTTPClientHeaders(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		userAgent string
+		wantAuth  string
+		wantUA    string
+	}{
+		{"api key", "", "", "", "porter-reporter/" + version},
+		{"bearer", "s3cret", "", "Bearer s3cret", "porter-reporter/" + version},
+		{"custom User-Agent", "", "garage-monitor/2", "", "garage-monitor/2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got http.Header
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Clone()
+			}))
+			defer srv.Close()
+
+			res, err := newPorterHTTPClient(nil, tt.token, tt.userAgent).Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			if ua := got.Get("User-Agent"); ua != tt.wantUA {
+				t.Errorf("User-Agent = %q, want %q", ua, tt.wantUA)
+			}
+			if auth := got.Get("Authorization"); auth != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", auth, tt.wantAuth)
+			}
+		})
+	}
+}
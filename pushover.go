@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const pushoverAPIURL = "https://api.pushover.net/1"
+
+const pushoverMaxMessage = 1024
+
+// Pushover priorities.
+const (
+	pushoverNormal    = 0
+	pushoverHigh      = 1
+	pushoverEmergency = 2
+)
+
+// PushoverNotifier sends messages through Pushover. Critical and escalated
+// messages are sent at emergency priority, which Pushover repeats every
+// Retry until acknowledged or Expire has passed; the repeats are cancelled
+// once the door closes or the controller recovers.
+type PushoverNotifier struct {
+	Token string // application API token
+	User  string // user or group key
+
+	Retry  time.Duration // at least 30 seconds
+	Expire time.Duration // at most 3 hours
+
+	// URL overrides the API base URL.
+	URL string
+
+	mu       sync.Mutex
+	receipts map[string]string // emergency receipts by door or controller
+}
+
+type pushoverResponse struct {
+	Status  int      `json:"status"`
+	Receipt string   `json:"receipt"`
+	Errors  []string `json:"errors"`
+}
+
+func pushoverPriority(ev Event) int {
+	switch {
+	case ev.Severity == SeverityCritical || ev.Escalated:
+		return pushoverEmergency
+	case ev.Severity == SeverityWarning:
+		return pushoverHigh
+	default:
+		return pushoverNormal
+	}
+}
+
+func (p *PushoverNotifier) Send(ctx context.Context, msg string) error {
+	ev, _ := eventFromContext(ctx)
+	keys := ev.Doors
+	if len(keys) == 0 {
+		keys = []string{ev.DoorName}
+	}
+
+	priority := pushoverPriority(ev)
+	switch {
+	case ev.Type == MsgStateChangeClosed || ev.Type == MsgMonitorRecover:
+		p.cancel(ctx, ev.DoorName)
+	case priority == pushoverEmergency:
+		// A repeat replaces the emergency still being repeated for the
+		// same door rather than adding another.
+		for _, key := range keys {
+			p.cancel(ctx, key)
+		}
+	}
+
+	v := url.Values{}
+	v.Set("token", p.Token)
+	v.Set("user", p.User)
+	v.Set("title", "Porter")
+	v.Set("message", truncate(msg, pushoverMaxMessage))
+	v.Set("priority", strconv.Itoa(priority))
+	if priority == pushoverEmergency {
+		v.Set("retry", strconv.Itoa(int(p.Retry.Seconds())))
+		v.Set("expire", strconv.Itoa(int(p.Expire.Seconds())))
+	}
+
+	res, err := p.post(ctx, "/messages.json", v)
+	if err != nil {
+		return err
+	}
+
+	if res.Receipt != "" {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.receipts == nil {
+			p.receipts = make(map[string]string)
+		}
+		for _, key := range keys {
+			p.receipts[key] = res.Receipt
+		}
+	}
+	return nil
+}
+
+// cancel stops Pushover repeating an emergency message that is no longer
+// relevant. Failures are only logged, as the repeats expire on their own.
+func (p *PushoverNotifier) cancel(ctx context.Context, key string) {
+	p.mu.Lock()
+	receipt, ok := p.receipts[key]
+	delete(p.receipts, key)
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	v := url.Values{}
+	v.Set("token", p.Token)
+	if _, err := p.post(ctx, "/receipts/"+url.PathEscape(receipt)+"/cancel.json", v); err != nil {
+		slog.Warn("failed to cancel pushover emergency", "door", key, "error", err)
+	}
+}
+
+func (p *PushoverNotifier) post(ctx context.Context, path string, v url.Values) (*pushoverResponse, error) {
+	base := p.URL
+	if base == "" {
+		base = pushoverAPIURL
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(base, "/")+path, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("building pushover request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	res, err := postWithRetry(ctx, httpClient, req, webhookRetryPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("sending to pushover: %w", err)
+	}
+	defer res.Body.Close()
+
+	po := &pushoverResponse{}
+	json.NewDecoder(io.LimitReader(res.Body, 4096)).Decode(po)
+	if res.StatusCode < 200 || res.StatusCode > 299 || po.Status != 1 {
+		return nil, &httpStatusError{Service: "pushover", Status: res.StatusCode, Detail: strings.Join(po.Errors, "; ")}
+	}
+	return po, nil
+}
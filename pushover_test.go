@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPushoverPriority(t *testing.T) {
+	tests := []struct {
+		ev   Event
+		want int
+	}{
+		{Event{Severity: SeverityInfo}, pushoverNormal},
+		{Event{Severity: SeverityWarning}, pushoverHigh},
+		{Event{Severity: SeverityWarning, Escalated: true}, pushoverEmergency},
+		{Event{Severity: SeverityCritical}, pushoverEmergency},
+	}
+
+	for _, tt := range tests {
+		if got := pushoverPriority(tt.ev); got != tt.want {
+			t.Errorf("pushoverPriority(%+v) = %d, want %d", tt.ev, got, tt.want)
+		}
+	}
+}
+
+// fakePushover records requests as "priority N [retry expire]" for messages
+// and "cancel RECEIPT" for cancellations, handing out receipts R1, R2, ... for
+// emergency messages.
+type fakePushover struct {
+	mu       sync.Mutex
+	receipts int
+	requests []string
+}
+
+func (f *fakePushover) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if r.PostForm.Get("token") != "APPTOKEN" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status": 0, "errors": ["application token is invalid"]}`))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if receipt, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/receipts/"), "/cancel.json"); ok {
+		f.requests = append(f.requests, "cancel "+receipt)
+		w.Write([]byte(`{"status": 1}`))
+		return
+	}
+
+	record := "priority " + r.PostForm.Get("priority")
+	if r.PostForm.Get("priority") != "2" {
+		f.requests = append(f.requests, record)
+		w.Write([]byte(`{"status": 1}`))
+		return
+	}
+	f.receipts++
+	f.requests = append(f.requests, record+" "+r.PostForm.Get("retry")+" "+r.PostForm.Get("expire"))
+	fmt.Fprintf(w, `{"status": 1, "receipt": "R%d"}`, f.receipts)
+}
+
+func (f *fakePushover) take() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	requests := f.requests
+	f.requests = nil
+	return requests
+}
+
+func TestPushoverNotifier(t *testing.T) {
+	fake := &fakePushover{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	p := &PushoverNotifier{Token: "APPTOKEN", User: "USERKEY", Retry: time.Minute, Expire: time.Hour, URL: srv.URL}
+
+	send := func(ev Event) func() error {
+		return func() error { return p.Send(withEvent(context.Background(), ev), "Garage is open.") }
+	}
+
+	steps := []struct {
+		name string
+		do   func() error
+		want []string
+	}{
+		{"info", send(Event{Type: MsgManual}), []string{"priority 0"}},
+		{"warning", send(Event{Type: MsgStateChangeOpen, DoorName: "garage", Severity: SeverityWarning}), []string{"priority 1"}},
+		{"escalated", send(Event{Type: MsgStateChangeOpen, DoorName: "garage", Severity: SeverityWarning, Escalated: true}), []string{"priority 2 60 3600"}},
+		{"repeat replaces the emergency", send(Event{Type: MsgStateChangeOpen, DoorName: "garage", Severity: SeverityCritical}), []string{"cancel R1", "priority 2 60 3600"}},
+		{"other door's close leaves it", send(Event{Type: MsgStateChangeClosed, DoorName: "shed"}), []string{"priority 0"}},
+		{"close cancels it", send(Event{Type: MsgStateChangeClosed, DoorName: "garage"}), []string{"cancel R2", "priority 0"}},
+		{"nothing left to cancel", send(Event{Type: MsgStateChangeClosed, DoorName: "garage"}), []string{"priority 0"}},
+		{"batch", send(Event{Type: MsgBatchOpen, Doors: []string{"garage", "shed"}, Severity: SeverityCritical}), []string{"priority 2 60 3600"}},
+		{"close of one batched door", send(Event{Type: MsgStateChangeClosed, DoorName: "shed"}), []string{"cancel R3", "priority 0"}},
+	}
+
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := fake.take(); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: requested %q, want %q", step.name, got, step.want)
+		}
+	}
+}
+
+func TestPushoverRejected(t *testing.T) {
+	srv := httptest.NewServer(&fakePushover{})
+	defer srv.Close()
+	p := &PushoverNotifier{Token: "wrong", User: "USERKEY", URL: srv.URL}
+
+	err := p.Send(context.Background(), "Garage is open.")
+	var statusErr *httpStatusError
+	if !errors.As(err, &statusErr) || err.Error() != "pushover returned HTTP 400: application token is invalid" {
+		t.Fatalf("Send() error = %v, want the API's errors", err)
+	}
+}
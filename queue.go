@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// queueRetryInterval is how often a queued notification that a channel
+	// failed to take is tried again on that channel.
+	queueRetryInterval = 30 * time.Second
+
+	// queueMaxAge is how long a notification is retried before it's given
+	// up on as too stale to be worth sending.
+	queueMaxAge = 24 * time.Hour
+)
+
+// notifyQueue keeps notifications on disk until they have been delivered, so
+// that one interrupted by a crash, or that a channel failed to take, is sent
+// later rather than lost. Items are keyed by their dedup key: a newer message for
+// the same event replaces a pending one instead of queueing a duplicate.
+type notifyQueue struct {
+	path string
+
+	mu    sync.Mutex
+	items map[string]*queuedMsg
+	seq   uint64
+}
+
+type queuedMsg struct {
+	Event  Event     `json:"event"`
+	Msg    string    `json:"msg"`
+	Queued time.Time `json:"queued"`
+	Tried  time.Time `json:"tried,omitempty"`
+
+	// Delivered and GivenUp are the channels, by QueueNotifier ID, that have
+	// taken the message or rejected it in a way retrying won't fix. Neither
+	// is sent it again.
+	Delivered []string `json:"delivered,omitempty"`
+	GivenUp   []string `json:"given_up,omitempty"`
+
+	// seq tells a replacement apart from the item it replaced, so that
+	// delivering the old one doesn't mark the new one done.
+	seq uint64
+}
+
+// openNotifyQueue loads the queue persisted at path. A missing file is an
+// empty queue.
+func openNotifyQueue(path string) (*notifyQueue, error) {
+	q := &notifyQueue{path: path, items: make(map[string]*queuedMsg)}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return q, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading queue file: %w", err)
+	}
+
+	var items map[string]*queuedMsg
+	if err := json.Unmarshal(b, &items); err != nil {
+		return nil, fmt.Errorf("parsing queue file %s: %w", path, err)
+	}
+	for key, item := range items {
+		q.seq++
+		item.seq = q.seq
+		q.items[key] = item
+	}
+	return q, nil
+}
+
+// add persists a notification about to be sent and returns it as queued.
+func (q *notifyQueue) add(ev Event, msg string, now time.Time) queuedMsg {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	item := &queuedMsg{Event: ev, Msg: msg, Queued: now, Tried: now, seq: q.seq}
+	q.items[dedupKey(ev)] = item
+	q.save()
+	return *item
+}
+
+// settle records the channels that took a notification, or won't ever, so
+// that only the rest are retried.
+func (q *notifyQueue) settle(key string, seq uint64, delivered, givenUp []string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	item, ok := q.items[key]
+	if !ok || item.seq != seq || len(delivered)+len(givenUp) == 0 {
+		return
+	}
+	item.Delivered = append(item.Delivered, delivered...)
+	item.GivenUp = append(item.GivenUp, givenUp...)
+	q.save()
+}
+
+// done removes a delivered notification, unless it has since been replaced.
+func (q *notifyQueue) done(key string, seq uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if item, ok := q.items[key]; ok && item.seq == seq {
+		delete(q.items, key)
+		q.save()
+	}
+}
+
+// due returns the notifications to try again now, oldest first, and drops
+// those that have gone stale.
+func (q *notifyQueue) due(now time.Time) []queuedMsg {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var due []queuedMsg
+	changed := false
+	for key, item := range q.items {
+		switch {
+		case now.Sub(item.Queued) > queueMaxAge:
+			slog.Warn("giving up on queued notification", "type", eventName(item.Event.Type), "door", item.Event.DoorName, "queued", item.Queued)
+			delete(q.items, key)
+			changed = true
+		case now.Sub(item.Tried) >= queueRetryInterval:
+			item.Tried = now
+			changed = true
+			due = append(due, *item)
+		}
+	}
+	if changed {
+		q.save()
+	}
+
+	sort.Slice(due, func(i, j int) bool { return due[i].Queued.Before(due[j].Queued) })
+	return due
+}
+
+// save writes the queue to disk. q.mu must be held.
+func (q *notifyQueue) save() {
+	b, err := json.Marshal(q.items)
+	if err == nil {
+		err = writeFileAtomic(q.path, b)
+	}
+	if err != nil {
+		slog.Error("failed to save notification queue", "error", err)
+	}
+}
+
+// retryQueued resends queued notifications that are due, including any left
+// over from before a restart.
+func (a *App) retryQueued(ctx context.Context) {
+	if a.queue == nil {
+		return
+	}
+	for _, item := range a.queue.due(a.clock.Now()) {
+		slog.Info("retrying queued notification", "type", eventName(item.Event.Type), "door", item.Event.DoorName, "queued", item.Queued)
+		a.sendQueued(ctx, item)
+	}
+}
+
+// sendQueued sends a queued notification to the channels that haven't
+// settled it, and drops it once none are left that a retry could help.
+func (a *App) sendQueued(ctx context.Context, item queuedMsg) {
+	p := &queueProgress{settled: make(map[string]error), tried: make(map[string]error)}
+	for _, id := range item.Delivered {
+		p.settled[id] = nil
+	}
+	for _, id := range item.GivenUp {
+		p.settled[id] = errGivenUp
+	}
+
+	key := dedupKey(item.Event)
+	err := a.send(withQueueProgress(ctx, p), item.Event, item.Msg)
+	if err == nil || retryWontHelp(err) {
+		if err != nil {
+			slog.Warn("not retrying notification", "type", eventName(item.Event.Type), "door", item.Event.DoorName, "error", err)
+		}
+		a.queue.done(key, item.seq)
+		return
+	}
+
+	var delivered, givenUp []string
+	for id, err := range p.tried {
+		switch {
+		case err == nil:
+			delivered = append(delivered, id)
+		case retryWontHelp(err):
+			givenUp = append(givenUp, id)
+		}
+	}
+	a.queue.settle(key, item.seq, delivered, givenUp)
+}
+
+// errGivenUp stands in for a channel's earlier permanent failure when a
+// queued notification is retried.
+var errGivenUp = errors.New("channel rejected the notification")
+
+// retryWontHelp reports whether every failure in err is one that sending
+// again won't fix, such as a 4xx response or the SMS budget running out.
+func retryWontHelp(err error) bool {
+	for err != nil {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				if !retryWontHelp(e) {
+					return false
+				}
+			}
+			return true
+		}
+		if s, ok := err.(interface{ httpStatus() int }); ok {
+			status := s.httpStatus()
+			return status >= 400 && status < 500 && status != http.StatusTooManyRequests
+		}
+		if err == errSMSBudget || err == errGivenUp {
+			return true
+		}
+		err = errors.Unwrap(err)
+	}
+	return false
+}
+
+// queueProgress tracks which channels a queued notification reached.
+type queueProgress struct {
+	settled map[string]error // from earlier attempts; read only
+
+	mu    sync.Mutex
+	tried map[string]error
+}
+
+type queueProgressKey struct{}
+
+func withQueueProgress(ctx context.Context, p *queueProgress) context.Context {
+	return context.WithValue(ctx, queueProgressKey{}, p)
+}
+
+// QueueNotifier identifies one channel to the notification queue, so that a
+// retry skips it once it has taken the message.
+type QueueNotifier struct {
+	Notifier Notifier
+	ID       string // stable across restarts with the same configuration
+}
+
+func (q *QueueNotifier) Send(ctx context.Context, msg string) error {
+	p, ok := ctx.Value(queueProgressKey{}).(*queueProgress)
+	if !ok {
+		return q.Notifier.Send(ctx, msg)
+	}
+	if err, ok := p.settled[q.ID]; ok {
+		return err
+	}
+
+	err := q.Notifier.Send(ctx, msg)
+	p.mu.Lock()
+	p.tried[q.ID] = err
+	p.mu.Unlock()
+	return err
+}
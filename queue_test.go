@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNotifyQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	q, err := openNotifyQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open := Event{Type: MsgStateChangeOpen, DoorName: "garage", Changed: start}
+	first := q.add(open, "open 15m", start)
+	second := q.add(open, "open 30m", start) // replaces the first
+	q.add(Event{Type: MsgStateChangeClosed, DoorName: "garage", Changed: start.Add(time.Hour)}, "closed", start)
+
+	q.done(dedupKey(open), first.seq)
+	if len(q.items) != 2 {
+		t.Fatalf("marking a replaced item done removed its replacement: %d items", len(q.items))
+	}
+	q.settle(dedupKey(open), second.seq, []string{"sms#0"}, []string{"email#1"})
+
+	if due := q.due(start.Add(time.Second)); len(due) != 0 {
+		t.Errorf("%d items due before the retry interval", len(due))
+	}
+
+	// A restart picks up where it left off.
+	q, err = openNotifyQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := q.due(start.Add(queueRetryInterval))
+	if len(due) != 2 {
+		t.Fatalf("%d items due after a restart, want 2", len(due))
+	}
+	for _, item := range due {
+		if item.Event.Type != MsgStateChangeOpen {
+			continue
+		}
+		if item.Msg != "open 30m" || fmt.Sprint(item.Delivered) != "[sms#0]" || fmt.Sprint(item.GivenUp) != "[email#1]" {
+			t.Errorf("reloaded item = %+v", item)
+		}
+	}
+
+	if due := q.due(start.Add(queueMaxAge + time.Minute)); len(due) != 0 {
+		t.Errorf("stale items were retried: %+v", due)
+	}
+	if len(q.items) != 0 {
+		t.Errorf("%d stale items kept", len(q.items))
+	}
+}
+
+func TestSendQueued(t *testing.T) {
+	unavailable := &httpStatusError{Service: "slack", Status: 503}
+	rejected := &httpStatusError{Service: "webhook", Status: 400}
+
+	tests := []struct {
+		name      string
+		channels  map[string][]error // scripted failures per channel
+		wantCalls map[string]int     // after the first send and one retry
+		wantQueue bool               // still queued after the retry
+	}{
+		{
+			name:      "all delivered",
+			channels:  map[string][]error{"a": nil, "b": nil},
+			wantCalls: map[string]int{"a": 1, "b": 1},
+		},
+		{
+			name:      "only the failed channel is retried",
+			channels:  map[string][]error{"a": nil, "b": {unavailable}},
+			wantCalls: map[string]int{"a": 1, "b": 2},
+		},
+		{
+			name:      "still failing",
+			channels:  map[string][]error{"a": nil, "b": {unavailable, unavailable}},
+			wantCalls: map[string]int{"a": 1, "b": 2},
+			wantQueue: true,
+		},
+		{
+			name:      "rejected channels are given up on",
+			channels:  map[string][]error{"a": {unavailable}, "b": {rejected}},
+			wantCalls: map[string]int{"a": 2, "b": 1},
+		},
+		{
+			name:      "dropped when nothing can be retried",
+			channels:  map[string][]error{"a": {rejected}, "b": {fmt.Errorf("+18005550199: %w", errSMSBudget)}},
+			wantCalls: map[string]int{"a": 1, "b": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+			channels := make(map[string]*scriptedNotifier)
+			var notifiers []Notifier
+			for _, id := range []string{"a", "b"} {
+				channels[id] = &scriptedNotifier{errs: tt.channels[id]}
+				notifiers = append(notifiers, &QueueNotifier{Notifier: channels[id], ID: id})
+			}
+
+			app := NewApp(Config{}, nil, &MultiNotifier{Notifiers: notifiers}, nil)
+			clock := newFakeClock(now)
+			app.clock = clock
+			var err error
+			if app.queue, err = openNotifyQueue(filepath.Join(t.TempDir(), "queue.json")); err != nil {
+				t.Fatal(err)
+			}
+
+			app.deliver(context.Background(), Event{Type: MsgStateChangeOpen, DoorName: "garage", Changed: now}, "open")
+			clock.Advance(queueRetryInterval)
+			app.retryQueued(context.Background())
+
+			for id, want := range tt.wantCalls {
+				if got := channels[id].count(); got != want {
+					t.Errorf("channel %s sent %d times, want %d", id, got, want)
+				}
+			}
+			if got := len(app.queue.items) > 0; got != tt.wantQueue {
+				t.Errorf("queued = %v, want %v", got, tt.wantQueue)
+			}
+		})
+	}
+}
+
+func TestRetryWontHelp(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"network error", errors.New("connection reset"), false},
+		{"server error", &httpStatusError{Status: 502}, false},
+		{"rate limited", &httpStatusError{Status: 429}, false},
+		{"bad request", &httpStatusError{Status: 400}, true},
+		{"twilio rejection", fmt.Errorf("+18005550199: %w", &twilioError{Status: 400, Code: 21211}), true},
+		{"twilio outage", &twilioError{Status: 503}, false},
+		{"matrix forbidden", &matrixError{Status: 403}, true},
+		{"sms budget", fmt.Errorf("+18005550199: %w", errSMSBudget), true},
+		{"breaker open", errBreakerOpen, false},
+		{"all permanent", errors.Join(&httpStatusError{Status: 404}, errSMSBudget), true},
+		{"some retryable", errors.Join(&httpStatusError{Status: 404}, &httpStatusError{Status: 500}), false},
+	}
+
+	for _, tt := range tests {
+		if got := retryWontHelp(tt.err); got != tt.want {
+			t.Errorf("retryWontHelp(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// QuietHours is a daily window, possibly wrapping past midnight, during which
+// door notifications are held back. Errors, lifecycle messages and anything
+// critical are not affected.
+type QuietHours struct {
+	Start, End time.Duration // offsets from midnight in the time passed to Contains
+	Defer      bool          // queue suppressed messages instead of dropping them
+}
+
+type deferredMsg struct {
+	ev  Event
+	msg string
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (q *QuietHours) Contains(t time.Time) bool {
+	if q == nil || q.Start == q.End {
+		return false
+	}
+
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if q.Start < q.End {
+		return offset >= q.Start && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
+
+func (q *QuietHours) suppresses(msgType int, t time.Time) bool {
+	if msgSeverity(msgType) == SeverityCritical {
+		return false
+	}
+
+	switch msgType {
+	case MsgStateChangeOpen, MsgStateChangeClosed, MsgDoorOpened, MsgBatchOpen, MsgFlapping, MsgDoorMissing, MsgDailyOpenLimit:
+		return q.Contains(t)
+	default:
+		return false
+	}
+}
+
+// deferMsg queues a suppressed message. Only the latest message of each type
+// is kept per door.
+func (a *App) deferMsg(ev Event, msg string) {
+	a.deferredMu.Lock()
+	defer a.deferredMu.Unlock()
+
+	for i := range a.deferred {
+		if a.deferred[i].ev.Type == ev.Type && a.deferred[i].ev.DoorName == ev.DoorName {
+			a.deferred[i] = deferredMsg{ev: ev, msg: msg}
+			return
+		}
+	}
+	a.deferred = append(a.deferred, deferredMsg{ev: ev, msg: msg})
+}
+
+// flushDeferred sends any queued messages once quiet hours are over.
+func (a *App) flushDeferred(ctx context.Context) {
+	if a.cfg.QuietHours.Contains(a.clock.Now().In(a.cfg.TimeLocation)) {
+		return
+	}
+
+	a.deferredMu.Lock()
+	pending := a.deferred
+	a.deferred = nil
+	a.deferredMu.Unlock()
+
+	for _, d := range pending {
+		a.deliver(ctx, d.ev, d.msg)
+	}
+}
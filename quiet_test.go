@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestQuietHoursContains(t *testing.T) {
+	at := func(hour, min int) time.Time { return time.Date(2024, 3, 1, hour, min, 0, 0, time.UTC) }
+	overnight := &QuietHours{Start: 22 * time.Hour, End: 7 * time.Hour}
+	daytime := &QuietHours{Start: 9 * time.Hour, End: 17*time.Hour + 30*time.Minute}
+
+	tests := []struct {
+		name string
+		q    *QuietHours
+		t    time.Time
+		want bool
+	}{
+		{"unset", nil, at(23, 0), false},
+		{"empty window", &QuietHours{Start: 8 * time.Hour, End: 8 * time.Hour}, at(8, 0), false},
+		{"overnight before", overnight, at(21, 59), false},
+		{"overnight start", overnight, at(22, 0), true},
+		{"overnight midnight", overnight, at(0, 0), true},
+		{"overnight morning", overnight, at(6, 59), true},
+		{"overnight end", overnight, at(7, 0), false},
+		{"daytime start", daytime, at(9, 0), true},
+		{"daytime middle", daytime, at(12, 0), true},
+		{"daytime end", daytime, at(17, 30), false},
+		{"daytime evening", daytime, at(20, 0), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.q.Contains(tt.t); got != tt.want {
+			t.Errorf("%s: Contains(%s) = %v, want %v", tt.name, tt.t.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestQuietHoursSuppresses(t *testing.T) {
+	q := &QuietHours{Start: 22 * time.Hour, End: 7 * time.Hour}
+	night := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		msgType int
+		t       time.Time
+		want    bool
+	}{
+		{MsgStateChangeOpen, night, true},
+		{MsgStateChangeClosed, night, true},
+		{MsgBatchOpen, night, true},
+		{MsgDailyOpenLimit, night, true},
+		{MsgStateChangeOpen, day, false},
+		{MsgMonitorError, night, false}, // critical
+		{MsgMonitorStarting, night, false},
+		{MsgDigest, night, false},
+	}
+
+	for _, tt := range tests {
+		if got := q.suppresses(tt.msgType, tt.t); got != tt.want {
+			t.Errorf("suppresses(%s, %s) = %v, want %v", eventName(tt.msgType), tt.t.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestQuietHoursTimeZone(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*60*60)
+	quiet := &QuietHours{Start: 22 * time.Hour, End: 7 * time.Hour}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool // whether the message is sent
+	}{
+		// 02:00 UTC is 21:00 in the configured zone.
+		{"before quiet hours", time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC), true},
+		// 04:00 UTC is 23:00 in the configured zone.
+		{"during quiet hours", time.Date(2024, 3, 1, 4, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingNotifier{}
+			app := NewApp(Config{TimeLocation: loc, QuietHours: quiet}, nil, rec, nil)
+			app.clock = newFakeClock(tt.now)
+			app.notify(context.Background(), MsgStateChangeOpen, "garage", time.Hour, tt.now.Add(-time.Hour))
+			if got := len(rec.sent()) == 1; got != tt.want {
+				t.Errorf("sent = %v, want %v", got, tt.want)
+			}
+
+			rec = &recordingNotifier{}
+			c := &ContactNotifier{Notifier: rec, Contact: Contact{Channel: "sms", Quiet: quiet}, Clock: app.clock, Location: loc}
+			c.Send(withEvent(context.Background(), Event{Type: MsgStateChangeOpen, DoorName: "garage"}), "open")
+			if got := len(rec.sent()) == 1; got != tt.want {
+				t.Errorf("contact sent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"00:00", 0, false},
+		{"07:30", 7*time.Hour + 30*time.Minute, false},
+		{"23:59", 23*time.Hour + 59*time.Minute, false},
+		{"24:00", 0, true},
+		{"7pm", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseClock(tt.s)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseClock(%q) = %v, %v; want %v, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestOpenAlertAcrossQuietHours(t *testing.T) {
+	for _, deferred := range []bool{false, true} {
+		t.Run(fmt.Sprintf("defer %v", deferred), func(t *testing.T) {
+			cfg := Config{
+				OpenThreshold: 30 * time.Minute,
+				QuietHours:    &QuietHours{Start: 22 * time.Hour, End: 7 * time.Hour, Defer: deferred},
+				TimeLocation:  time.UTC,
+			}
+			// The garage is left open at 21:50, with a single alert per
+			// open event.
+			garage := []stubDoor{{name: "garage", open: true, since: 13*time.Hour + 50*time.Minute}}
+			runPollSteps(t, cfg, []pollStep{
+				{at: 14 * time.Hour, doors: garage},
+				{at: 14*time.Hour + 20*time.Minute},
+				{at: 22*time.Hour + 59*time.Minute},
+				{at: 23 * time.Hour, want: []string{"garage has been open for 9 hours"}},
+				{at: 23*time.Hour + time.Minute},
+				{at: 26 * time.Hour},
+			})
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// secretFlags lists the flags that may also be read from a file (via the
+// "-<name>-file" flag) or an environment variable, to keep secrets out of
+// process listings.
+var secretFlags = []struct {
+	name string
+	env  string
+}{
+	{"twtoken", "PORTER_TWILIO_TOKEN"},
+	{"pkey", "PORTER_API_KEY"},
+	{"smtppass", "PORTER_SMTP_PASSWORD"},
+	{"tgtoken", "PORTER_TELEGRAM_TOKEN"},
+	{"apikey", "PORTER_REPORTER_API_KEY"},
+	{"mqttpass", "PORTER_MQTT_PASSWORD"},
+	{"ntfytoken", "PORTER_NTFY_TOKEN"},
+	{"pdroutingkey", "PORTER_PAGERDUTY_KEY"},
+	{"matrixtoken", "PORTER_MATRIX_TOKEN"},
+	{"opsgeniekey", "PORTER_OPSGENIE_KEY"},
+	{"pushovertoken", "PORTER_PUSHOVER_TOKEN"},
+}
+
+var secretFiles = make(map[string]*string)
+
+func registerSecretFlags(fs *flag.FlagSet) {
+	for _, s := range secretFlags {
+		secretFiles[s.name] = fs.String(s.name+"-file", "", fmt.Sprintf("Read -%s from this file", s.name))
+	}
+}
+
+// applySecrets fills in secret flags that weren't set directly, preferring a
+// -<name>-file over the environment. cmdline holds the flags given on the
+// command line, which win over the config file: a -<name>-file there
+// replaces a value for <name> from the file.
+func applySecrets(fs *flag.FlagSet, cmdline map[string]bool) error {
+	set := setFlags(fs)
+
+	for _, s := range secretFlags {
+		if cmdline[s.name] || set[s.name] && !cmdline[s.name+"-file"] {
+			continue
+		}
+
+		if path := *secretFiles[s.name]; path != "" {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("reading -%s-file: %w", s.name, err)
+			}
+			if err := fs.Set(s.name, strings.TrimRight(string(b), "\r\n")); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if v, ok := os.LookupEnv(s.env); ok {
+			if err := fs.Set(s.name, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// setFlags returns the names of the flags in fs that have been set.
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
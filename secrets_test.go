@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplySecretsPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		config string // JSON config file contents, if any
+		env    string
+		want   string
+	}{
+		{"flag", []string{"-twtoken", "from-flag", "-twtoken-file", tokenFile}, "", "from-env", "from-flag"},
+		{"file over env", []string{"-twtoken-file", tokenFile}, "", "from-env", "from-file"},
+		{"env", nil, "", "from-env", "from-env"},
+		{"config over env", nil, `{"twtoken": "from-config"}`, "from-env", "from-config"},
+		{"config value over config file", nil, `{"twtoken": "from-config", "twtoken-file": "` + tokenFile + `"}`, "", "from-config"},
+		{"config file", nil, `{"twtoken-file": "` + tokenFile + `"}`, "", "from-file"},
+		{"command line file over config", []string{"-twtoken-file", tokenFile}, `{"twtoken": "from-config"}`, "", "from-file"},
+		{"flag over config", []string{"-twtoken", "from-flag"}, `{"twtoken": "from-config"}`, "", "from-flag"},
+		{"unset", nil, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PORTER_TWILIO_TOKEN", tt.env)
+			if tt.env == "" {
+				os.Unsetenv("PORTER_TWILIO_TOKEN")
+			}
+
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			token := fs.String("twtoken", "", "")
+			for _, s := range secretFlags {
+				if s.name != "twtoken" {
+					fs.String(s.name, "", "")
+				}
+			}
+			registerSecretFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			cmdline := setFlags(fs)
+			if tt.config != "" {
+				path := filepath.Join(t.TempDir(), "config.json")
+				if err := os.WriteFile(path, []byte(tt.config), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if err := applyConfigFile(fs, path); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := applySecrets(fs, cmdline); err != nil {
+				t.Fatal(err)
+			}
+
+			if *token != tt.want {
+				t.Errorf("twtoken = %q, want %q", *token, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Severity ranks how urgently a message needs attention.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return "unknown"
+	}
+}
+
+func parseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "info":
+		return SeverityInfo, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "critical":
+		return SeverityCritical, nil
+	default:
+		return 0, fmt.Errorf("unknown severity %q, expected info, warning or critical", s)
+	}
+}
+
+func msgSeverity(msgType int) Severity {
+	switch msgType {
+	case MsgMonitorError:
+		return SeverityCritical
+	case MsgStateChangeOpen, MsgBatchOpen, MsgClockSkew, MsgMonitorRecover, MsgFlapping, MsgSMSBudget, MsgDoorMissing, MsgDailyOpenLimit:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+// SeverityNotifier passes on only messages at or above MinSeverity. Messages
+// below it are dropped without error.
+type SeverityNotifier struct {
+	Notifier    Notifier
+	MinSeverity Severity
+}
+
+func (s *SeverityNotifier) Send(ctx context.Context, msg string) error {
+	if ev, ok := eventFromContext(ctx); ok && ev.Severity < s.MinSeverity {
+		return nil
+	}
+	return s.Notifier.Send(ctx, msg)
+}
+
+// parseChannelSeverities parses 'channel=severity,...' into the minimum
+// severity for each channel.
+func parseChannelSeverities(s string) (map[string]Severity, error) {
+	severities := make(map[string]Severity)
+	if s == "" {
+		return severities, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		channel, level, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid channel severity %q, expected channel=severity", entry)
+		}
+		sev, err := parseSeverity(level)
+		if err != nil {
+			return nil, err
+		}
+		severities[strings.TrimSpace(channel)] = sev
+	}
+
+	return severities, nil
+}
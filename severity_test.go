@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSeverityNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		min     Severity
+		ctx     context.Context
+		wantOut bool
+	}{
+		{"no event", SeverityCritical, context.Background(), true},
+		{"info below warning", SeverityWarning, withEvent(context.Background(), Event{Type: MsgDoorOpened, Severity: SeverityInfo}), false},
+		{"warning at warning", SeverityWarning, withEvent(context.Background(), Event{Type: MsgStateChangeOpen, Severity: SeverityWarning}), true},
+		{"critical above warning", SeverityWarning, withEvent(context.Background(), Event{Type: MsgMonitorError, Severity: SeverityCritical}), true},
+		{"warning below critical", SeverityCritical, withEvent(context.Background(), Event{Type: MsgStateChangeOpen, Severity: SeverityWarning}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingNotifier{}
+			if err := (&SeverityNotifier{Notifier: rec, MinSeverity: tt.min}).Send(tt.ctx, "hello"); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(rec.sent()) == 1; got != tt.wantOut {
+				t.Errorf("passed on = %v, want %v", got, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestParseChannelSeverities(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    map[string]Severity
+		wantErr bool
+	}{
+		{"", map[string]Severity{}, false},
+		{"slack=info,sms=Critical", map[string]Severity{"slack": SeverityInfo, "sms": SeverityCritical}, false},
+		{" email = warning ", map[string]Severity{"email": SeverityWarning}, false},
+		{"slack", nil, true},
+		{"slack=loud", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseChannelSeverities(tt.s)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("parseChannelSeverities(%q) = %v, %v; want %v, error %v", tt.s, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCriticalRouting(t *testing.T) {
+	tests := []struct {
+		msgType      int
+		wantCritical bool
+	}{
+		{MsgMonitorError, true},
+		{MsgStateChangeOpen, false},
+		{MsgMonitorStarting, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(eventName(tt.msgType), func(t *testing.T) {
+			rec, critical := &recordingNotifier{}, &recordingNotifier{}
+			app := NewApp(Config{}, nil, rec, nil)
+			app.criticalNotifier = critical
+
+			app.notify(context.Background(), tt.msgType, "garage")
+			if len(rec.sent()) != 1 {
+				t.Errorf("sent %d messages to the usual channels, want 1", len(rec.sent()))
+			}
+			if got := len(critical.sent()) == 1; got != tt.wantCritical {
+				t.Errorf("sent to critical recipients = %v, want %v", got, tt.wantCritical)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SignalNotifier sends messages through a signal-cli REST API bridge
+// (github.com/bbernhard/signal-cli-rest-api).
+type SignalNotifier struct {
+	URL        string // bridge URL, e.g. http://localhost:8080
+	Number     string // the registered number to send from
+	Recipients []string
+}
+
+type signalMessage struct {
+	Message    string   `json:"message"`
+	Number     string   `json:"number"`
+	Recipients []string `json:"recipients"`
+}
+
+func (s *SignalNotifier) Send(ctx context.Context, msg string) error {
+	payload, err := json.Marshal(signalMessage{Message: msg, Number: s.Number, Recipients: s.Recipients})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(s.URL, "/")+"/v2/send", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("building signal request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	res, err := postWithRetry(ctx, httpClient, req, webhookRetryPolicy)
+	if err != nil {
+		return fmt.Errorf("sending to signal: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return &httpStatusError{Service: "signal", Status: res.StatusCode, Detail: apiErr.Error}
+		}
+		return &httpStatusError{Service: "signal", Status: res.StatusCode, Detail: strings.TrimSpace(string(body))}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSignalNotifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"sent", http.StatusCreated, `{"timestamp": "1709280000000"}`, ""},
+		{"bridge error", http.StatusBadRequest, `{"error": "Invalid account (phone number)"}`, "signal returned HTTP 400: Invalid account (phone number)"},
+		{"not JSON", http.StatusNotFound, "404 page not found\n", "signal returned HTTP 404: 404 page not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got signalMessage
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/v2/send" {
+					t.Errorf("%s %s, want POST /v2/send", r.Method, r.URL.Path)
+				}
+				json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			s := &SignalNotifier{URL: srv.URL + "/", Number: "+18005550100", Recipients: []string{"+18005550199", "group.abc123"}}
+			err := s.Send(context.Background(), "Garage is open.")
+			if tt.wantErr != "" {
+				var statusErr *httpStatusError
+				if !errors.As(err, &statusErr) || err.Error() != tt.wantErr {
+					t.Fatalf("Send() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := signalMessage{Message: "Garage is open.", Number: "+18005550100", Recipients: s.Recipients}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("posted %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// twilioSignature computes the X-Twilio-Signature for a request to fullURL
+// with the given POST parameters.
+func twilioSignature(authToken, fullURL string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(fullURL)
+	for _, k := range keys {
+		for _, v := range params[k] {
+			b.WriteString(k)
+			b.WriteString(v)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validTwilioRequest reports whether r carries a valid X-Twilio-Signature.
+// r.PostForm must already be parsed. publicURL is the URL Twilio was
+// configured to call; when empty it is reconstructed from the request, which
+// may not match if the endpoint sits behind a proxy. Without an auth token,
+// no request is valid.
+func validTwilioRequest(r *http.Request, authToken, publicURL string) bool {
+	sig := r.Header.Get("X-Twilio-Signature")
+	if sig == "" || authToken == "" {
+		return false
+	}
+
+	fullURL := publicURL
+	if fullURL == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		fullURL = scheme + "://" + r.Host + r.URL.RequestURI()
+	}
+
+	expected := twilioSignature(authToken, fullURL, r.PostForm)
+	return hmac.Equal([]byte(sig), []byte(expected))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTwilioSignature(t *testing.T) {
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+
+	// The URL followed by each parameter name and value in name order,
+	// HMAC-SHA1 signed with the auth token and base64 encoded.
+	const want = "vNe7KK2kJwCsxc9K3OLkkKB3qqI="
+	if got := twilioSignature("12345", "https://example.com/myapp.php?foo=1&bar=2", params); got != want {
+		t.Errorf("twilioSignature = %q, want %q", got, want)
+	}
+}
+
+func TestValidTwilioRequest(t *testing.T) {
+	const token = "s3cret"
+	form := url.Values{"From": {"+18005550199"}, "Body": {"mute garage"}}
+	sign := func(u string) string { return twilioSignature(token, u, form) }
+
+	tests := []struct {
+		name      string
+		target    string
+		header    map[string]string
+		body      url.Values
+		token     string
+		publicURL string
+		want      bool
+	}{
+		{"valid", "http://reporter.local/sms", map[string]string{"X-Twilio-Signature": sign("http://reporter.local/sms")}, form, token, "", true},
+		{"missing signature", "http://reporter.local/sms", nil, form, token, "", false},
+		{"wrong token", "http://reporter.local/sms", map[string]string{"X-Twilio-Signature": twilioSignature("other", "http://reporter.local/sms", form)}, form, token, "", false},
+		{"tampered body", "http://reporter.local/sms", map[string]string{"X-Twilio-Signature": sign("http://reporter.local/sms")}, url.Values{"From": {"+18005550100"}, "Body": {"mute garage"}}, token, "", false},
+		{"query string is signed", "http://reporter.local/sms?x=1", map[string]string{"X-Twilio-Signature": sign("http://reporter.local/sms?x=1")}, form, token, "", true},
+		{"behind a TLS proxy", "http://reporter.local/sms", map[string]string{"X-Twilio-Signature": sign("https://reporter.local/sms"), "X-Forwarded-Proto": "https"}, form, token, "", true},
+		{"public URL", "http://10.0.0.2:8080/sms", map[string]string{"X-Twilio-Signature": sign("https://porter.example.com/sms")}, form, token, "https://porter.example.com/sms", true},
+		{"no auth token", "http://reporter.local/sms", map[string]string{"X-Twilio-Signature": twilioSignature("", "http://reporter.local/sms", form)}, form, "", "", false},
+		{"public URL mismatch", "http://10.0.0.2:8080/sms", map[string]string{"X-Twilio-Signature": sign("http://10.0.0.2:8080/sms")}, form, token, "https://porter.example.com/sms", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", tt.target, strings.NewReader(tt.body.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if err := r.ParseForm(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := validTwilioRequest(r, tt.token, tt.publicURL); got != tt.want {
+				t.Errorf("validTwilioRequest = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
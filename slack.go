@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type SlackNotifier struct {
+	WebhookURL string
+}
+
+func (s *SlackNotifier) Send(ctx context.Context, msg string) error {
+	payload, err := json.Marshal(map[string]string{"text": slackFormat(ctx, msg)})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("building slack request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	res, err := postWithRetry(ctx, httpClient, req, webhookRetryPolicy)
+	if err != nil {
+		return fmt.Errorf("sending to slack: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return &httpStatusError{Service: "slack", Status: res.StatusCode}
+	}
+
+	return nil
+}
+
+func slackFormat(ctx context.Context, msg string) string {
+	ev, ok := eventFromContext(ctx)
+	if !ok {
+		return msg
+	}
+
+	switch ev.Type {
+	case MsgStateChangeOpen, MsgMonitorError:
+		return ":warning: " + msg
+	case MsgStateChangeClosed, MsgMonitorRecover:
+		return ":white_check_mark: " + msg
+	default:
+		return msg
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSlackNotifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		status   int
+		wantText string
+		wantErr  bool
+	}{
+		{"plain", context.Background(), http.StatusOK, "Garage is open.", false},
+		{"open event", withEvent(context.Background(), Event{Type: MsgStateChangeOpen}), http.StatusOK, ":warning: Garage is open.", false},
+		{"closed event", withEvent(context.Background(), Event{Type: MsgStateChangeClosed}), http.StatusOK, ":white_check_mark: Garage is open.", false},
+		{"rejected", context.Background(), http.StatusNotFound, "Garage is open.", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Content-Type %q", ct)
+				}
+				json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			err := (&SlackNotifier{WebhookURL: srv.URL}).Send(tt.ctx, "Garage is open.")
+			var statusErr *httpStatusError
+			switch {
+			case tt.wantErr && !(errors.As(err, &statusErr) && statusErr.Status == tt.status):
+				t.Errorf("Send() error = %v, want HTTP %d", err, tt.status)
+			case !tt.wantErr && err != nil:
+				t.Errorf("Send() error = %v", err)
+			}
+			if got["text"] != tt.wantText {
+				t.Errorf("text %q, want %q", got["text"], tt.wantText)
+			}
+		})
+	}
+}
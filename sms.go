@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	gsm7Basic     = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsm7Extension = "^{}\\[~]|€\f"
+)
+
+// smsCost returns how many encoding units each rune of msg takes, and the
+// units that fit in a single segment and in each part of a multipart message.
+// Messages that are all GSM-7 use 7-bit units; anything else is sent as UCS-2.
+func smsCost(msg string) (cost func(rune) int, single, multi int) {
+	gsm := true
+	for _, r := range msg {
+		if !strings.ContainsRune(gsm7Basic, r) && !strings.ContainsRune(gsm7Extension, r) {
+			gsm = false
+			break
+		}
+	}
+
+	if gsm {
+		return func(r rune) int {
+			if strings.ContainsRune(gsm7Extension, r) {
+				return 2
+			}
+			return 1
+		}, 160, 153
+	}
+	return func(r rune) int {
+		if r > 0xffff {
+			return 2 // surrogate pair
+		}
+		return 1
+	}, 70, 67
+}
+
+// smsSegments returns how many segments Twilio will bill msg as.
+func smsSegments(msg string) int {
+	cost, single, multi := smsCost(msg)
+	units := 0
+	for _, r := range msg {
+		units += cost(r)
+	}
+	if units <= single {
+		return 1
+	}
+	return (units + multi - 1) / multi
+}
+
+// splitSMS splits msg into messages that each fit in one segment, prefixed
+// with "(1/2) " and so on, breaking at spaces where possible.
+func splitSMS(msg string) []string {
+	cost, single, _ := smsCost(msg)
+	if smsSegments(msg) == 1 {
+		return []string{msg}
+	}
+
+	// The prefix length depends on the number of parts, so retry until the
+	// guess holds.
+	for digits, limit := 1, 10; ; digits, limit = digits+1, limit*10 {
+		prefixLen := len("(/) ") + 2*digits
+		parts := splitUnits(msg, single-prefixLen, cost)
+		if len(parts) < limit {
+			for i := range parts {
+				parts[i] = fmt.Sprintf("(%d/%d) %s", i+1, len(parts), parts[i])
+			}
+			return parts
+		}
+	}
+}
+
+func splitUnits(msg string, max int, cost func(rune) int) []string {
+	r := []rune(msg)
+	var parts []string
+	for len(r) > 0 {
+		units, end, lastSpace := 0, 0, -1
+		for end < len(r) && units+cost(r[end]) <= max {
+			units += cost(r[end])
+			if r[end] == ' ' || r[end] == '\n' {
+				lastSpace = end
+			}
+			end++
+		}
+
+		cut := end
+		if end < len(r) && lastSpace > end/2 {
+			cut = lastSpace
+		}
+		parts = append(parts, strings.TrimRight(string(r[:cut]), " \n"))
+		for cut < len(r) && (r[cut] == ' ' || r[cut] == '\n') {
+			cut++
+		}
+		r = r[cut:]
+	}
+	return parts
+}
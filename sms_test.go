@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSMSSegments(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want int
+	}{
+		{"empty", "", 1},
+		{"short", "Garage has been open for 15 minutes.", 1},
+		{"160 GSM-7", strings.Repeat("a", 160), 1},
+		{"161 GSM-7", strings.Repeat("a", 161), 2},
+		{"extension characters count twice", strings.Repeat("€", 80), 1},
+		{"extension characters over a segment", strings.Repeat("€", 81), 2},
+		{"70 UCS-2", strings.Repeat("é", 69) + "ł", 1},
+		{"71 UCS-2", strings.Repeat("ł", 71), 2},
+		{"three GSM-7 parts", strings.Repeat("a", 153*3), 3},
+	}
+
+	for _, tt := range tests {
+		if got := smsSegments(tt.msg); got != tt.want {
+			t.Errorf("smsSegments(%s) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSplitSMS(t *testing.T) {
+	long := strings.Repeat("word ", 100)
+	tests := []struct {
+		name      string
+		msg       string
+		wantParts int
+	}{
+		{"single segment is left alone", "Garage has been open for 15 minutes.", 1},
+		{"GSM-7", long, 4},
+		{"UCS-2", strings.Repeat("łódź ", 40), 4},
+		{"no spaces", strings.Repeat("x", 400), 3},
+		{"ten or more parts", strings.Repeat("word ", 400), 14},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := splitSMS(tt.msg)
+			if len(parts) != tt.wantParts {
+				t.Fatalf("got %d parts, want %d: %q", len(parts), tt.wantParts, parts)
+			}
+			if len(parts) == 1 {
+				if parts[0] != tt.msg {
+					t.Errorf("single part = %q, want the message unchanged", parts[0])
+				}
+				return
+			}
+
+			var rejoined []string
+			for i, part := range parts {
+				if n := smsSegments(part); n != 1 {
+					t.Errorf("part %d takes %d segments: %q", i+1, n, part)
+				}
+				prefix := fmt.Sprintf("(%d/%d) ", i+1, len(parts))
+				body, ok := strings.CutPrefix(part, prefix)
+				if !ok {
+					t.Errorf("part %d = %q, want prefix %q", i+1, part, prefix)
+				}
+				if !utf8.ValidString(body) {
+					t.Errorf("part %d splits a character", i+1)
+				}
+				rejoined = append(rejoined, body)
+			}
+
+			// Only the whitespace at the breaks may be lost.
+			if got, want := strings.Join(strings.Fields(strings.Join(rejoined, " ")), ""), strings.Join(strings.Fields(tt.msg), ""); got != want {
+				t.Errorf("parts don't add up to the message:\ngot  %q\nwant %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type doorWatchState struct {
+	LastStateChangeTS    time.Time `json:"last_state_change"`
+	LastNotificationSent time.Time `json:"last_notification_sent"`
+	Repeats              int       `json:"repeats,omitempty"`
+	OpenedTS             time.Time `json:"opened_announced,omitempty"`
+	CalledTS             time.Time `json:"called,omitempty"`
+	Closed               bool      `json:"closed,omitempty"`
+	LastClosed           time.Time `json:"last_closed,omitempty"`
+	LastOpened           time.Time `json:"last_opened,omitempty"`
+}
+
+func (d *DoorWatch) MarshalJSON() ([]byte, error) {
+	return json.Marshal(doorWatchState{
+		LastStateChangeTS:    d.lastStateChangeTS,
+		LastNotificationSent: d.lastNotificationSent,
+		Repeats:              d.repeats,
+		OpenedTS:             d.openedTS,
+		CalledTS:             d.calledTS,
+		Closed:               d.closed,
+		LastClosed:           d.lastClosed,
+		LastOpened:           d.lastOpened,
+	})
+}
+
+func (d *DoorWatch) UnmarshalJSON(b []byte) error {
+	var s doorWatchState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	d.lastStateChangeTS = s.LastStateChangeTS
+	d.lastNotificationSent = s.LastNotificationSent
+	d.repeats = s.Repeats
+	d.openedTS = s.OpenedTS
+	d.calledTS = s.CalledTS
+	d.closed = s.Closed
+	d.lastClosed = s.LastClosed
+	d.lastOpened = s.LastOpened
+	return nil
+}
+
+type savedState struct {
+	Doors         map[string]*DoorWatch `json:"doors"`
+	ErrorNotified map[string]bool       `json:"error_notified,omitempty"` // by controller label
+	Mutes         map[string]time.Time  `json:"mutes,omitempty"`          // zero until the door closes
+}
+
+// loadState reads persisted monitor state from path. A missing file is not an
+// error and yields empty state.
+func loadState(path string) (*savedState, error) {
+	state := &savedState{Doors: make(map[string]*DoorWatch)}
+	if path == "" {
+		return state, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("parsing state file: %w", err)
+	}
+	if state.Doors == nil {
+		state.Doors = make(map[string]*DoorWatch)
+	}
+
+	return state, nil
+}
+
+// saveState atomically writes monitor state to path.
+func saveState(path string, state *savedState) error {
+	if path == "" {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(path, b); err != nil {
+		return fmt.Errorf("writing state file: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the file at path with b, so that a crash leaves
+// either the old contents or the new, never a mix.
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// reconcileState drops persisted watches for doors a controller no longer
+// reports. owns selects the keys belonging to that controller. Doors that
+// changed state while we were down are handled by the normal monitor logic,
+// since their state change timestamp will differ.
+func reconcileState(doors map[string]*DoorWatch, owns func(string) bool, present map[string]bool) bool {
+	changed := false
+	for key := range doors {
+		if owns(key) && !present[key] {
+			delete(doors, key)
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hako/durafmt"
+)
+
+// statusLogLines is how many recent log lines the status view shows.
+const statusLogLines = 10
+
+// statusView redraws a summary of every door on the terminal for -status,
+// with the most recent log lines beneath it. Logs are written to it rather
+// than straight to the terminal so they don't scroll the summary away.
+type statusView struct {
+	out io.Writer
+
+	mu      sync.Mutex
+	lines   []string
+	partial []byte
+	stopped bool
+}
+
+// isTerminal reports whether f is a terminal rather than a file or pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Write collects log output for the view, or passes it straight through
+// once the view has stopped.
+func (v *statusView) Write(p []byte) (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.stopped {
+		return v.out.Write(p)
+	}
+
+	v.partial = append(v.partial, p...)
+	for {
+		i := bytes.IndexByte(v.partial, '\n')
+		if i < 0 {
+			break
+		}
+		v.lines = append(v.lines, string(v.partial[:i]))
+		v.partial = v.partial[i+1:]
+	}
+	if len(v.lines) > statusLogLines {
+		v.lines = v.lines[len(v.lines)-statusLogLines:]
+	}
+	return len(p), nil
+}
+
+// run redraws the view every second until ctx is done, then clears it and
+// prints the retained log lines so nothing is lost.
+func (v *statusView) run(ctx context.Context, a *App) {
+	ticker := a.clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		v.mu.Lock()
+		fmt.Fprint(v.out, v.render(a, a.clock.Now()))
+		v.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			v.stop()
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+func (v *statusView) stop() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.stopped = true
+	fmt.Fprint(v.out, "\033[H\033[2J")
+	for _, line := range v.lines {
+		fmt.Fprintln(v.out, line)
+	}
+	v.out.Write(v.partial)
+	v.lines, v.partial = nil, nil
+}
+
+// render draws the whole screen. v.mu must be held.
+func (v *statusView) render(a *App, now time.Time) string {
+	doors := a.latestDoors.list()
+	open := 0
+	for _, d := range doors {
+		if d.Open {
+			open++
+		}
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("\033[H\033[2J")
+	fmt.Fprintf(b, "\033[1mPorter reporter\033[0m  %d doors, %d open  %s\n\n", len(doors), open, now.In(a.cfg.TimeLocation).Format(a.cfg.TimeFormat))
+	if len(doors) == 0 {
+		b.WriteString("Waiting for the first poll...\n")
+	}
+	for _, d := range doors {
+		state, openFor := "\033[32mclosed\033[0m", ""
+		if d.Open {
+			state = "\033[31mopen  \033[0m"
+			openFor = durafmt.ParseShort(now.Sub(d.LastChange)).String()
+		}
+		muted := ""
+		if a.mutes.isMuted(d.Name, now) {
+			muted = "\033[33mmuted\033[0m"
+		}
+		fmt.Fprintf(b, "  %-24s %s  %-12s %s\n", a.displayName(d.Name), state, openFor, muted)
+	}
+
+	if len(v.lines) > 0 {
+		b.WriteString("\n\033[2m")
+		for _, line := range v.lines {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("\033[0m")
+	}
+	return b.String()
+}
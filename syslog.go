@@ -0,0 +1,106 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"log/syslog"
+	"strings"
+	"sync"
+)
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"lpr":      syslog.LOG_LPR,
+	"news":     syslog.LOG_NEWS,
+	"uucp":     syslog.LOG_UUCP,
+	"cron":     syslog.LOG_CRON,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"ftp":      syslog.LOG_FTP,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+// newSyslogHandler returns a handler that sends records to the local syslog
+// daemon, formatted as text without the timestamp syslog adds itself.
+func newSyslogHandler(facility, tag string, opts *slog.HandlerOptions) (slog.Handler, error) {
+	prio, ok := syslogFacilities[strings.ToLower(facility)]
+	if !ok {
+		return nil, fmt.Errorf("invalid syslog facility %q", facility)
+	}
+
+	w, err := syslog.New(prio|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to syslog: %w", err)
+	}
+	return newSyslogWriterHandler(w, opts), nil
+}
+
+type syslogHandler struct {
+	slog.Handler
+	out *syslogOutput
+}
+
+// syslogOutput is shared by a syslogHandler and those derived from it with
+// WithAttrs or WithGroup.
+type syslogOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	w   *syslog.Writer
+}
+
+func newSyslogWriterHandler(w *syslog.Writer, opts *slog.HandlerOptions) *syslogHandler {
+	out := &syslogOutput{w: w}
+	textOpts := *opts
+	textOpts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+			return slog.Attr{}
+		}
+		return a
+	}
+	return &syslogHandler{Handler: slog.NewTextHandler(&out.buf, &textOpts), out: out}
+}
+
+func (h *syslogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+
+	h.out.buf.Reset()
+	if err := h.Handler.Handle(ctx, r); err != nil {
+		return err
+	}
+	line := strings.TrimSuffix(h.out.buf.String(), "\n")
+
+	switch {
+	case r.Level >= slog.LevelError:
+		return h.out.w.Err(line)
+	case r.Level >= slog.LevelWarn:
+		return h.out.w.Warning(line)
+	case r.Level >= slog.LevelInfo:
+		return h.out.w.Info(line)
+	default:
+		return h.out.w.Debug(line)
+	}
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syslogHandler{Handler: h.Handler.WithAttrs(attrs), out: h.out}
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{Handler: h.Handler.WithGroup(name), out: h.out}
+}
@@ -0,0 +1,12 @@
+//go:build windows || plan9
+
+package main
+
+import (
+	"errors"
+	"log/slog"
+)
+
+func newSyslogHandler(facility, tag string, opts *slog.HandlerOptions) (slog.Handler, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
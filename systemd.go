@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemdNotifier reports readiness and watchdog pings to systemd over the
+// sd_notify protocol. A nil *systemdNotifier, as when not run under systemd,
+// does nothing.
+type systemdNotifier struct {
+	socket string
+
+	// watchdog is the WatchdogSec interval, or zero if the watchdog isn't
+	// enabled for this process.
+	watchdog time.Duration
+}
+
+var systemd = newSystemdNotifier()
+
+func newSystemdNotifier() *systemdNotifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:] // abstract namespace
+	}
+
+	s := &systemdNotifier{socket: socket}
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		pid := os.Getenv("WATCHDOG_PID")
+		if pid == "" || pid == strconv.Itoa(os.Getpid()) {
+			s.watchdog = time.Duration(usec) * time.Microsecond
+		}
+	}
+	return s
+}
+
+func (s *systemdNotifier) send(state string) {
+	if s == nil {
+		return
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: s.socket, Net: "unixgram"})
+	if err != nil {
+		slog.Debug("failed to notify systemd", "error", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		slog.Debug("failed to notify systemd", "error", err)
+	}
+}
+
+func (s *systemdNotifier) ready() {
+	s.send("READY=1")
+}
+
+func (s *systemdNotifier) stopping() {
+	s.send("STOPPING=1")
+}
+
+// ping resets the watchdog timer, if the watchdog is enabled.
+func (s *systemdNotifier) ping() {
+	if s != nil && s.watchdog > 0 {
+		s.send("WATCHDOG=1")
+	}
+}
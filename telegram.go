@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const telegramMaxMessageLength = 4096
+
+type TelegramNotifier struct {
+	Token   string
+	ChatIDs []string
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+}
+
+func (t *TelegramNotifier) Send(ctx context.Context, msg string) error {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+
+	var errs []error
+	for _, chatID := range t.ChatIDs {
+		for _, part := range splitMessage(msg, telegramMaxMessageLength) {
+			if err := t.sendMessage(ctx, httpClient, chatID, part); err != nil {
+				errs = append(errs, fmt.Errorf("chat %s: %w", chatID, err))
+				break
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (t *TelegramNotifier) sendMessage(ctx context.Context, httpClient *http.Client, chatID, text string) error {
+	payload, err := json.Marshal(map[string]string{"chat_id": chatID, "text": text})
+	if err != nil {
+		return err
+	}
+
+	apiUrl := "https://api.telegram.org/bot" + t.Token + "/sendMessage"
+	req, err := http.NewRequestWithContext(ctx, "POST", apiUrl, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("building telegram request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	res, err := postWithRetry(ctx, httpClient, req, webhookRetryPolicy)
+	if err != nil {
+		return fmt.Errorf("sending to telegram: %w", err)
+	}
+	defer res.Body.Close()
+
+	tgRes := telegramResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&tgRes); err != nil {
+		return &httpStatusError{Service: "telegram", Status: res.StatusCode}
+	}
+	if !tgRes.OK {
+		return fmt.Errorf("telegram error %d: %s", tgRes.ErrorCode, tgRes.Description)
+	}
+
+	return nil
+}
+
+// splitMessage breaks msg into chunks of at most max runes, preferring to
+// split on whitespace.
+func splitMessage(msg string, max int) []string {
+	r := []rune(msg)
+	var parts []string
+	for len(r) > max {
+		cut := max
+		for i := max; i > max/2; i-- {
+			if r[i] == ' ' || r[i] == '\n' {
+				cut = i
+				break
+			}
+		}
+		end := cut
+		for end > 0 && (r[end-1] == ' ' || r[end-1] == '\n') {
+			end--
+		}
+		parts = append(parts, string(r[:end]))
+		for cut < len(r) && (r[cut] == ' ' || r[cut] == '\n') {
+			cut++
+		}
+		r = r[cut:]
+	}
+	return append(parts, string(r))
+}
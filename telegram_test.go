@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// redirectDefaultTransport sends requests made through http.DefaultTransport
+// to srv for the rest of the test, for notifiers whose API URL is fixed.
+func redirectDefaultTransport(t *testing.T, srv *httptest.Server) {
+	target, _ := url.Parse(srv.URL)
+	orig := http.DefaultTransport
+	http.DefaultTransport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		r.URL.Scheme, r.URL.Host = target.Scheme, target.Host
+		return srv.Client().Transport.RoundTrip(r)
+	})
+	t.Cleanup(func() { http.DefaultTransport = orig })
+}
+
+func TestTelegramNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	limited := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bottoken123/sendMessage" {
+			t.Errorf("request to %s", r.URL.Path)
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		switch body["chat_id"] {
+		case "missing":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"ok": false, "error_code": 400, "description": "Bad Request: chat not found"}`))
+			return
+		case "broken":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<html>not found</html>"))
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if body["chat_id"] == "limited" && !limited {
+			limited = true
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"ok": false, "error_code": 429, "description": "Too Many Requests: retry after 1"}`))
+			return
+		}
+		sent = append(sent, body["chat_id"]+": "+body["text"])
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+	redirectDefaultTransport(t, srv)
+
+	tg := &TelegramNotifier{Token: "token123", ChatIDs: []string{"1", "missing", "broken", "limited", "2"}}
+	err := tg.Send(context.Background(), "Garage is open.")
+
+	var statusErr *httpStatusError
+	if err == nil || !strings.Contains(err.Error(), "chat missing: telegram error 400: Bad Request: chat not found") {
+		t.Errorf("Send() error = %v, want the API's description for chat missing", err)
+	}
+	if !errors.As(err, &statusErr) || statusErr.Status != http.StatusNotFound {
+		t.Errorf("Send() error = %v, want HTTP 404 for chat broken", err)
+	}
+	if strings.Contains(err.Error(), "limited") {
+		t.Errorf("Send() error = %v, want chat limited retried after its Retry-After", err)
+	}
+	if want := []string{"1: Garage is open.", "limited: Garage is open.", "2: Garage is open."}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent %q, want %q", sent, want)
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		max  int
+		want []string
+	}{
+		{"empty", "", 10, []string{""}},
+		{"exactly max", "aaaaaaaaaa", 10, []string{"aaaaaaaaaa"}},
+		{"no whitespace", "aaaaaaaaaaa", 10, []string{"aaaaaaaaaa", "a"}},
+		{"space at max", "aaaaaaaaaa bbb", 10, []string{"aaaaaaaaaa", "bbb"}},
+		{"split on space", "aaaaaa bbbbbbb", 10, []string{"aaaaaa", "bbbbbbb"}},
+		{"space too early", "aaaaa bbbbbbbbb", 10, []string{"aaaaa bbbb", "bbbbb"}},
+		{"newlines skipped", "aaaaaaa\n\n\nbbb", 10, []string{"aaaaaaa", "bbb"}},
+		{"runes", "ééééééééééé", 10, []string{"éééééééééé", "é"}},
+		{"several parts", "aaaa bbbb cccc dddd", 5, []string{"aaaa", "bbbb", "cccc", "dddd"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitMessage(tt.msg, tt.max); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitMessage(%q, %d) = %q, want %q", tt.msg, tt.max, got, tt.want)
+			}
+		})
+	}
+}
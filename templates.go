@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/template"
+)
+
+var defaultMsgTemplates = map[int]string{
+	MsgStateChangeOpen:   "[{{.Time}}] Porter notice: {{.DoorName}} has been open for {{.Duration}}.",
+	MsgStateChangeClosed: "[{{.Time}}] Porter notice: {{.DoorName}} is now closed.",
+	MsgMonitorStarting:   "[{{.Time}}] Porter notice: Door monitor started.",
+	MsgMonitorDying:      "[{{.Time}}] Porter notice: Door monitor is stopping.{{if .Summary}} {{.Summary}}{{end}}",
+	MsgMonitorError:      "[{{.Time}}] Porter notice: I'm having trouble reaching the door controller{{if .DoorName}} {{.DoorName}}{{end}}. The network might be offline, or the controller may need to be rebooted. I won't send any more messages until I can reach it.",
+	MsgMonitorRecover:    "[{{.Time}}] Porter notice: The garage door controller{{if .DoorName}} {{.DoorName}}{{end}} is back online! Status updates will resume.",
+	MsgTest:              "[{{.Time}}] Porter notice: This is a test notification.",
+	MsgDigest:            "[{{.Time}}] Porter daily summary: {{.OpenEvents}} door opening{{if ne .OpenEvents 1}}s{{end}}{{if .LongestDoor}}, longest was {{.LongestDoor}} at {{.Longest}}{{end}}. {{if .Doors}}Still open: {{range $i, $d := .Doors}}{{if $i}}, {{end}}{{$d.Name}} ({{$d.Duration}}){{end}}.{{else}}All doors are closed.{{end}}",
+	MsgBatchOpen:         "[{{.Time}}] Porter notice: {{len .Doors}} doors have been left open: {{range $i, $d := .Doors}}{{if $i}}, {{end}}{{$d.Name}} ({{$d.Duration}}){{end}}.",
+	MsgDoorOpened:        "[{{.Time}}] Porter notice: {{.DoorName}} was just opened.",
+	MsgFlapping:          "[{{.Time}}] Porter notice: {{.DoorName}} keeps opening and closing. The sensor may be faulty; I'll hold off on alerts for it until it settles down.",
+	MsgSMSBudget:         "[{{.Time}}] Porter notice: The daily SMS limit has been reached. No more texts will be sent until midnight.",
+	MsgDoorMissing:       "[{{.Time}}] Porter notice: {{.DoorName}} is no longer reporting. Its sensor may have been disconnected.",
+	MsgDailyOpenLimit:    "[{{.Time}}] Porter notice: {{.DoorName}} has been open for {{.Duration}} in total today.",
+	MsgClockSkew:         "[{{.Time}}] Porter notice: {{.DoorName}} reported a state change time that looks wrong. The door controller's clock may need to be checked.",
+}
+
+var msgTemplates = make(map[int]*template.Template)
+
+var msgFuncs = template.FuncMap{"ordinal": ordinal}
+
+// ordinal formats n as e.g. "1st", "2nd" or "11th".
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// msgData is the data available to message templates.
+type msgData struct {
+	Time     string
+	DoorName string
+	Duration string
+	Doors    []msgDoor
+
+	// OpensToday is how many times DoorName has opened since midnight.
+	OpensToday int
+
+	// Summary is the activity summary in the stopping message, with
+	// -stopsummary.
+	Summary string
+
+	// Daily digest fields.
+	OpenEvents  int
+	Longest     string
+	LongestDoor string
+}
+
+type msgDoor struct {
+	Name     string
+	Duration string
+}
+
+func init() {
+	for msgType, text := range defaultMsgTemplates {
+		if err := setMsgTemplate(msgType, text); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// setMsgTemplate parses text as the template for msgType, rejecting templates
+// that fail to render so mistakes surface at startup rather than mid-alert.
+func setMsgTemplate(msgType int, text string) error {
+	tmpl, err := parseMsgTemplate(msgType, text)
+	if err != nil {
+		return err
+	}
+
+	msgTemplates[msgType] = tmpl
+	return nil
+}
+
+func parseMsgTemplate(msgType int, text string) (*template.Template, error) {
+	tmpl, err := template.New(eventName(msgType)).Funcs(msgFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s message template: %w", eventName(msgType), err)
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, msgData{}); err != nil {
+		return nil, fmt.Errorf("parsing %s message template: %w", eventName(msgType), err)
+	}
+	return tmpl, nil
+}
+
+// loadTemplateFile reads a JSON object mapping message names (e.g. "open",
+// "closed") to template strings.
+func loadTemplateFile(path string) error {
+	tmpls, err := readTemplateFile(path)
+	if err != nil {
+		return err
+	}
+
+	for msgType, tmpl := range tmpls {
+		msgTemplates[msgType] = tmpl
+	}
+	return nil
+}
+
+func readTemplateFile(path string) (map[int]*template.Template, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading template file: %w", err)
+	}
+
+	overrides := make(map[string]string)
+	if err := json.Unmarshal(b, &overrides); err != nil {
+		return nil, fmt.Errorf("parsing template file %s: %w", path, err)
+	}
+
+	tmpls := make(map[int]*template.Template, len(overrides))
+	for name, text := range overrides {
+		msgType, ok := overridableMsgType(name)
+		if !ok {
+			return nil, fmt.Errorf("template file %s: unknown message %q", path, name)
+		}
+		tmpl, err := parseMsgTemplate(msgType, text)
+		if err != nil {
+			return nil, err
+		}
+		tmpls[msgType] = tmpl
+	}
+
+	return tmpls, nil
+}
+
+// loadLangTemplates reads the templates for each language from dir, one
+// file per language named like 'es.json' in the -templatefile format.
+// Messages a language doesn't translate are sent in the default language.
+func loadLangTemplates(dir string, langs []string) (map[string]map[int]*template.Template, error) {
+	byLang := make(map[string]map[int]*template.Template)
+	for _, lang := range langs {
+		if _, ok := byLang[lang]; ok {
+			continue
+		}
+		if dir == "" {
+			return nil, fmt.Errorf("language %q needs -langdir", lang)
+		}
+		tmpls, err := readTemplateFile(filepath.Join(dir, lang+".json"))
+		if err != nil {
+			return nil, err
+		}
+		byLang[lang] = tmpls
+	}
+	return byLang, nil
+}
+
+// overridableMsgType looks up a message whose template may be replaced with
+// -templatefile; that is any with a default template.
+func overridableMsgType(name string) (int, bool) {
+	msgType, ok := msgTypeByName(name)
+	if !ok {
+		return 0, false
+	}
+	_, ok = defaultMsgTemplates[msgType]
+	return msgType, ok
+}
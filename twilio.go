@@ -0,0 +1,342 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// twilioMaxBodyLength is the longest message body Twilio accepts.
+const twilioMaxBodyLength = 1600
+
+type TwilioNotifier struct {
+	AccountSID string
+	AuthToken  string
+
+	// Senders are the numbers messages are sent from, used in turn to spread
+	// load across them.
+	Senders []string
+
+	// MessagingServiceSID sends through a Messaging Service instead of from
+	// Senders. Exactly one of the two must be set.
+	MessagingServiceSID string
+
+	// Recipients may be replaced while sending with SetRecipients.
+	Recipients []string
+	Retries    int
+	mu         sync.RWMutex
+
+	// Concurrency caps how many recipients are sent to at once.
+	Concurrency int
+
+	// SnapshotURL, when set, is attached as an MMS picture to door open
+	// messages. DoorSnapshotURLs overrides it for individual doors.
+	SnapshotURL      string
+	DoorSnapshotURLs map[string]string
+
+	// SplitLong sends messages longer than one SMS segment as several
+	// single-segment texts instead of one concatenated one.
+	SplitLong bool
+
+	// Budget, when set, caps how many texts are sent per day. It may be
+	// shared between notifiers.
+	Budget *smsBudget
+
+	// Limiter, when set, throttles every request to the Twilio API. It may
+	// be shared between notifiers using the same account.
+	Limiter *rateLimiter
+
+	next atomic.Uint64
+
+	// HTTPClient is shared across sends so connections are pooled; when nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// WhatsApp sends through Twilio's WhatsApp channel instead of SMS.
+	WhatsApp bool
+}
+
+// twilioWhatsAppSessionError is the Twilio error for a free-form WhatsApp
+// message sent outside the 24 hour session window.
+const twilioWhatsAppSessionError = 63016
+
+type twilioError struct {
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+	MoreInfo string `json:"more_info"`
+	Status   int    `json:"status"`
+}
+
+// twilioMessage is the subset of Twilio's Message resource we care about.
+type twilioMessage struct {
+	SID          string  `json:"sid"`
+	Status       string  `json:"status"`
+	ErrorCode    *int    `json:"error_code"`
+	ErrorMessage *string `json:"error_message"`
+}
+
+func (e *twilioError) Error() string {
+	return fmt.Sprintf("twilio error %d (HTTP %d): %s", e.Code, e.Status, e.Message)
+}
+
+func (e *twilioError) httpStatus() int { return e.Status }
+
+func (t *TwilioNotifier) SetRecipients(recipients []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Recipients = recipients
+}
+
+func (t *TwilioNotifier) recipients() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.Recipients
+}
+
+func (t *TwilioNotifier) Send(ctx context.Context, msg string) error {
+	recipients := t.recipients()
+
+	workers := t.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(recipients) {
+		workers = len(recipients)
+	}
+
+	media := t.snapshotURL(ctx)
+
+	bodies := []string{msg}
+	if segments := smsSegments(msg); segments > 1 && !t.WhatsApp {
+		if t.SplitLong {
+			bodies = splitSMS(msg)
+		} else {
+			slog.Warn("message will be billed as several SMS segments", "segments", segments)
+		}
+	}
+
+	jobs := make(chan int)
+	errs := make([]error, len(recipients))
+	wg := &sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go (func(wg *sync.WaitGroup) {
+			defer wg.Done()
+			for i := range jobs {
+				to := recipients[i]
+				for part, body := range bodies {
+					if !t.Budget.take() {
+						errs[i] = fmt.Errorf("%s: %w", to, errSMSBudget)
+						break
+					}
+					partMedia := ""
+					if part == 0 {
+						partMedia = media
+					}
+					res, err := t.sendWithRetry(ctx, t.nextSender(), to, body, partMedia)
+					if err != nil {
+						metrics.smsFailed()
+						errs[i] = fmt.Errorf("%s: %w", to, err)
+						break
+					}
+					slog.Debug("sms sent", "sid", res.SID, "to", to, "status", res.Status)
+				}
+			}
+		})(wg)
+	}
+
+	for i := range recipients {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// nextSender picks the sender for a message round-robin.
+func (t *TwilioNotifier) nextSender() string {
+	if len(t.Senders) == 0 {
+		return ""
+	}
+	return t.Senders[(t.next.Add(1)-1)%uint64(len(t.Senders))]
+}
+
+// snapshotURL returns the picture to attach to the message in ctx, or "" if
+// there is none or it can't be reached, in which case the message is sent as
+// plain text.
+func (t *TwilioNotifier) snapshotURL(ctx context.Context) string {
+	ev, ok := eventFromContext(ctx)
+	if !ok || (ev.Type != MsgStateChangeOpen && ev.Type != MsgDoorOpened) {
+		return ""
+	}
+
+	media := t.SnapshotURL
+	if u, ok := t.DoorSnapshotURLs[ev.DoorName]; ok {
+		media = u
+	}
+	if media == "" {
+		return ""
+	}
+
+	httpClient := t.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, "HEAD", media, nil)
+	if err != nil {
+		slog.Warn("invalid snapshot URL", "door", ev.DoorName, "error", err)
+		return ""
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		slog.Warn("snapshot unavailable, sending text only", "door", ev.DoorName, "error", err)
+		return ""
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		slog.Warn("snapshot unavailable, sending text only", "door", ev.DoorName, "status", res.StatusCode)
+		return ""
+	}
+	return media
+}
+
+func (t *TwilioNotifier) sendWithRetry(ctx context.Context, sender, recipient, message, media string) (*twilioMessage, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := t.sendSMS(ctx, sender, recipient, message, media)
+		if err == nil {
+			return res, nil
+		}
+		if attempt >= t.Retries || !retryable(err) {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(backoff(attempt)):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+// retryable reports whether a failed send is worth another attempt. Twilio
+// rejecting the request outright (a 4xx other than rate limiting) is not.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var twErr *twilioError
+	if errors.As(err, &twErr) {
+		return twErr.Status == http.StatusTooManyRequests || twErr.Status >= 500
+	}
+
+	return true
+}
+
+// backoff returns an exponentially growing delay for the given attempt, with
+// ±50% jitter so that concurrent senders don't retry in lockstep.
+func backoff(attempt int) time.Duration {
+	d := time.Second << uint(attempt)
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
+func (t *TwilioNotifier) sendSMS(ctx context.Context, sender, recipient, message, media string) (*twilioMessage, error) {
+	if err := t.Limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	httpClient := t.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	apiUrl := strings.Join([]string{"https://api.twilio.com/2010-04-01/Accounts/", t.AccountSID, "/Messages.json"}, "")
+
+	if t.WhatsApp {
+		recipient = "whatsapp:" + recipient
+		if sender != "" {
+			sender = "whatsapp:" + sender
+		}
+	}
+
+	v := url.Values{}
+	v.Set("To", recipient)
+	switch {
+	case sender != "" && t.MessagingServiceSID != "":
+		return nil, errors.New("both a sender number and a messaging service are configured")
+	case sender != "":
+		v.Set("From", sender)
+	case t.MessagingServiceSID != "":
+		v.Set("MessagingServiceSid", t.MessagingServiceSID)
+	default:
+		return nil, errors.New("no sender number or messaging service configured")
+	}
+	v.Set("Body", message)
+	if media != "" {
+		v.Set("MediaUrl", media)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiUrl, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("building twilio request: %w", err)
+	}
+
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending to twilio: %w", err)
+	}
+	defer res.Body.Close()
+	defer io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		twErr := &twilioError{Status: res.StatusCode}
+		if err := json.NewDecoder(res.Body).Decode(twErr); err != nil || twErr.Message == "" {
+			twErr.Message = http.StatusText(res.StatusCode)
+		}
+		twErr.Status = res.StatusCode
+		return nil, t.explain(twErr)
+	}
+
+	msg := &twilioMessage{}
+	if err := json.NewDecoder(res.Body).Decode(msg); err != nil {
+		return nil, fmt.Errorf("decoding twilio response: %w", err)
+	}
+
+	if msg.ErrorCode != nil {
+		twErr := &twilioError{Code: *msg.ErrorCode, Status: res.StatusCode}
+		if msg.ErrorMessage != nil {
+			twErr.Message = *msg.ErrorMessage
+		}
+		return nil, t.explain(twErr)
+	}
+
+	if msg.Status == "failed" || msg.Status == "undelivered" {
+		return nil, &twilioError{Status: res.StatusCode, Message: "message " + msg.SID + " " + msg.Status}
+	}
+
+	return msg, nil
+}
+
+// explain adds a hint to errors whose cause isn't obvious from Twilio's
+// message.
+func (t *TwilioNotifier) explain(twErr *twilioError) error {
+	if t.WhatsApp && twErr.Code == twilioWhatsAppSessionError {
+		return fmt.Errorf("%w (WhatsApp only accepts free-form messages within 24 hours of the recipient last messaging your sender, so they need to message it first)", twErr)
+	}
+	return twErr
+}
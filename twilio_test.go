@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// fakeTwilio answers Messages.json requests, recording who was sent to.
+type fakeTwilio struct {
+	delay time.Duration
+
+	mu       sync.Mutex
+	sentTo   []string
+	inflight int
+	peak     int
+}
+
+func (f *fakeTwilio) client() *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		v, _ := url.ParseQuery(string(b))
+
+		f.mu.Lock()
+		f.inflight++
+		if f.inflight > f.peak {
+			f.peak = f.inflight
+		}
+		f.mu.Unlock()
+
+		time.Sleep(f.delay)
+
+		f.mu.Lock()
+		f.inflight--
+		f.sentTo = append(f.sentTo, v.Get("To"))
+		f.mu.Unlock()
+		return jsonResponse(201, `{"sid": "SM123", "status": "queued"}`), nil
+	})}
+}
+
+func TestTwilioSendReachesAllRecipients(t *testing.T) {
+	tests := []struct {
+		recipients  int
+		concurrency int
+	}{
+		{1, 1},
+		{5, 1},
+		{20, 4},
+		{3, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d recipients, concurrency %d", tt.recipients, tt.concurrency), func(t *testing.T) {
+			fake := &fakeTwilio{delay: 5 * time.Millisecond}
+			tw := &TwilioNotifier{AccountSID: "AC123", AuthToken: "token", Senders: []string{"+18005550100"}, Concurrency: tt.concurrency, HTTPClient: fake.client()}
+			for i := 0; i < tt.recipients; i++ {
+				tw.Recipients = append(tw.Recipients, fmt.Sprintf("+1800555%04d", i))
+			}
+
+			if err := tw.Send(context.Background(), "hello"); err != nil {
+				t.Fatal(err)
+			}
+
+			// Everything must have been sent by the time Send returns.
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			seen := make(map[string]bool)
+			for _, to := range fake.sentTo {
+				seen[to] = true
+			}
+			for _, to := range tw.Recipients {
+				if !seen[to] {
+					t.Errorf("%s was not sent to", to)
+				}
+			}
+			if len(fake.sentTo) != tt.recipients {
+				t.Errorf("sent %d messages, want %d", len(fake.sentTo), tt.recipients)
+			}
+			if fake.peak > tt.concurrency {
+				t.Errorf("%d sends were in flight at once, want at most %d", fake.peak, tt.concurrency)
+			}
+		})
+	}
+}
+
+func TestTwilioSendRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int // successive responses; the last repeats
+		retries   int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"success", []int{201}, 2, 1, false},
+		{"server error then success", []int{503, 201}, 1, 2, false},
+		{"rate limited then success", []int{429, 201}, 1, 2, false},
+		{"retries exhausted", []int{500}, 1, 2, true},
+		{"no retries", []int{500}, 0, 1, true},
+		{"client error isn't retried", []int{400}, 2, 1, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var calls atomic.Int32
+			client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				i := int(calls.Add(1)) - 1
+				if i >= len(tt.statuses) {
+					i = len(tt.statuses) - 1
+				}
+				if status := tt.statuses[i]; status != 201 {
+					return jsonResponse(status, `{"code": 20001, "message": "nope"}`), nil
+				}
+				return jsonResponse(201, `{"sid": "SM123", "status": "queued"}`), nil
+			})}
+			tw := &TwilioNotifier{AccountSID: "AC123", AuthToken: "token", Senders: []string{"+18005550100"}, Recipients: []string{"+18005550199"}, Retries: tt.retries, HTTPClient: client}
+
+			err := tw.Send(context.Background(), "hello")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := int(calls.Load()); got != tt.wantCalls {
+				t.Errorf("made %d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"network error", errors.New("connection refused"), true},
+		{"server error", &twilioError{Status: 500}, true},
+		{"rate limited", &twilioError{Status: 429}, true},
+		{"bad request", &twilioError{Status: 400}, false},
+		{"wrapped bad request", fmt.Errorf("sending: %w", &twilioError{Status: 401}), false},
+		{"canceled", context.Canceled, false},
+		{"timed out", fmt.Errorf("sending: %w", context.DeadlineExceeded), false},
+	}
+
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 5; attempt++ {
+		base := time.Second << uint(attempt)
+		for i := 0; i < 100; i++ {
+			if d := backoff(attempt); d < base/2 || d >= base*3/2 {
+				t.Fatalf("backoff(%d) = %v, want within ±50%% of %v", attempt, d, base)
+			}
+		}
+	}
+}
+
+func TestTwilioWhatsApp(t *testing.T) {
+	var mu sync.Mutex
+	var sent []url.Values
+	respond := jsonResponse(201, `{"sid": "SM123", "status": "queued"}`)
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(r.Body)
+		v, _ := url.ParseQuery(string(b))
+		mu.Lock()
+		sent = append(sent, v)
+		mu.Unlock()
+		return respond, nil
+	})}
+	tw := &TwilioNotifier{AccountSID: "AC123", AuthToken: "token", Senders: []string{"+18005550100"}, Recipients: []string{"+18005550199"}, WhatsApp: true, SplitLong: true, HTTPClient: client}
+
+	// Long messages go out whole, as WhatsApp isn't billed by segment.
+	long := strings.Repeat("word ", 100)
+	if err := tw.Send(context.Background(), long); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(sent))
+	}
+	if to, from, body := sent[0].Get("To"), sent[0].Get("From"), sent[0].Get("Body"); to != "whatsapp:+18005550199" || from != "whatsapp:+18005550100" || body != long {
+		t.Errorf("sent To=%q From=%q Body=%q", to, from, body)
+	}
+
+	// Outside the 24 hour session window, the error says what to do.
+	respond = jsonResponse(400, `{"code": 63016, "message": "Failed to send freeform message"}`)
+	err := tw.Send(context.Background(), "Garage is open.")
+	var twErr *twilioError
+	if !errors.As(err, &twErr) || twErr.Code != twilioWhatsAppSessionError {
+		t.Fatalf("Send() error = %v, want Twilio error %d", err, twilioWhatsAppSessionError)
+	}
+	if !strings.Contains(err.Error(), "need to message it first") {
+		t.Errorf("Send() error = %v, want a hint about the session window", err)
+	}
+}
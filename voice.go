@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TwilioVoiceNotifier places a phone call to each recipient that reads the
+// message aloud.
+type TwilioVoiceNotifier struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	Recipients []string
+
+	// HTTPClient is used for requests to Twilio; when nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+func (t *TwilioVoiceNotifier) Send(ctx context.Context, msg string) error {
+	var errs []error
+	for _, to := range t.Recipients {
+		sid, err := t.placeCall(ctx, to, msg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", to, err))
+			continue
+		}
+		slog.Debug("call placed", "sid", sid, "to", to)
+	}
+	return errors.Join(errs...)
+}
+
+func (t *TwilioVoiceNotifier) placeCall(ctx context.Context, recipient, message string) (string, error) {
+	httpClient := t.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	apiUrl := strings.Join([]string{"https://api.twilio.com/2010-04-01/Accounts/", t.AccountSID, "/Calls.json"}, "")
+
+	say := &strings.Builder{}
+	if err := xml.EscapeText(say, []byte(message)); err != nil {
+		return "", err
+	}
+
+	v := url.Values{}
+	v.Set("To", recipient)
+	v.Set("From", t.From)
+	v.Set("Twiml", "<Response><Say>"+say.String()+"</Say></Response>")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiUrl, strings.NewReader(v.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("building twilio request: %w", err)
+	}
+
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("calling twilio: %w", err)
+	}
+	defer res.Body.Close()
+	defer io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		twErr := &twilioError{Status: res.StatusCode}
+		if err := json.NewDecoder(res.Body).Decode(twErr); err != nil || twErr.Message == "" {
+			twErr.Message = http.StatusText(res.StatusCode)
+		}
+		twErr.Status = res.StatusCode
+		return "", twErr
+	}
+
+	call := &struct {
+		SID string `json:"sid"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(call); err != nil {
+		return "", fmt.Errorf("decoding twilio response: %w", err)
+	}
+	return call.SID, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTwilioVoiceNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var twiml []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2010-04-01/Accounts/AC123/Calls.json" {
+			t.Errorf("request to %s", r.URL.Path)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "AC123" || pass != "token" {
+			t.Errorf("basic auth %q, %q", user, pass)
+		}
+		r.ParseForm()
+		if from := r.PostForm.Get("From"); from != "+18005550100" {
+			t.Errorf("call from %q", from)
+		}
+		if r.PostForm.Get("To") == "+18005550000" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code": 21217, "message": "Phone number does not appear to be valid", "status": 400}`))
+			return
+		}
+		mu.Lock()
+		twiml = append(twiml, r.PostForm.Get("Twiml"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid": "CA123"}`))
+	}))
+	defer srv.Close()
+	redirectDefaultTransport(t, srv)
+
+	v := &TwilioVoiceNotifier{AccountSID: "AC123", AuthToken: "token", From: "+18005550100", Recipients: []string{"+18005550199", "+18005550000"}}
+	err := v.Send(context.Background(), "Garage & shed have been open for 2 hours.")
+
+	var twErr *twilioError
+	if !errors.As(err, &twErr) || twErr.Code != 21217 || !strings.Contains(err.Error(), "+18005550000") {
+		t.Errorf("Send() error = %v, want Twilio's error 21217 for +18005550000", err)
+	}
+	want := "<Response><Say>Garage &amp; shed have been open for 2 hours.</Say></Response>"
+	if len(twiml) != 1 || twiml[0] != want {
+		t.Errorf("calls read out %q, want one reading %q", twiml, want)
+	}
+}
+
+func TestCallOncePerOpenEvent(t *testing.T) {
+	const call = "call: ["
+	cfg := Config{OpenThreshold: 30 * time.Minute, RepeatThreshold: time.Hour, CallThreshold: 2 * time.Hour}
+
+	runPollSteps(t, cfg, []pollStep{
+		{at: 0, doors: []stubDoor{{name: "garage", open: true}}},
+		{at: 30 * time.Minute, want: []string{"garage has been open"}},
+		{at: 90 * time.Minute, want: []string{"garage has been open"}},
+		{at: 2 * time.Hour, want: []string{call}},
+		{at: 150 * time.Minute, want: []string{"garage has been open"}},
+		{at: 210 * time.Minute, want: []string{"garage has been open"}},
+		{at: 220 * time.Minute, doors: []stubDoor{{name: "garage", since: 220 * time.Minute}}, want: []string{"closed"}},
+		{at: 230 * time.Minute, doors: []stubDoor{{name: "garage", open: true, since: 230 * time.Minute}}},
+		{at: 350 * time.Minute, want: []string{call, "garage has been open"}},
+	}, func(app *App) {
+		app.voiceNotifier = &taggedNotifier{tag: "call: ", Notifier: app.notifier}
+	})
+}
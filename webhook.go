@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/hako/durafmt"
+)
+
+const defaultWebhookBody = `{"message": {{json .Message}}, "door": {{json .DoorName}}, "event": {{json .Event}}, "duration": {{json .Duration}}}`
+
+type WebhookNotifier struct {
+	URL         string
+	ContentType string
+	Body        *template.Template
+}
+
+type webhookData struct {
+	Message  string
+	DoorName string
+	Event    string
+	Duration string
+}
+
+func NewWebhookNotifier(url, body, contentType string) (*WebhookNotifier, error) {
+	if body == "" {
+		body = defaultWebhookBody
+	}
+
+	tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing webhook body template: %w", err)
+	}
+
+	return &WebhookNotifier{URL: url, ContentType: contentType, Body: tmpl}, nil
+}
+
+func (w *WebhookNotifier) Send(ctx context.Context, msg string) error {
+	data := webhookData{Message: msg}
+	if ev, ok := eventFromContext(ctx); ok {
+		data.DoorName = ev.DoorName
+		data.Event = eventName(ev.Type)
+		if ev.Duration > 0 {
+			data.Duration = durafmt.ParseShort(ev.Duration).String()
+		}
+	}
+
+	body := &bytes.Buffer{}
+	if err := w.Body.Execute(body, data); err != nil {
+		return fmt.Errorf("rendering webhook body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, body)
+	if err != nil {
+		return fmt.Errorf("building webhook request: %w", err)
+	}
+	if w.ContentType != "" {
+		req.Header.Add("Content-Type", w.ContentType)
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	res, err := postWithRetry(ctx, httpClient, req, webhookRetryPolicy)
+	if err != nil {
+		return fmt.Errorf("sending webhook: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		resBody, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return &httpStatusError{Service: "webhook", Status: res.StatusCode, Detail: truncate(string(resBody), 256)}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	openEvent := withEvent(context.Background(), Event{Type: MsgStateChangeOpen, DoorName: "Garage", Duration: 90 * time.Minute})
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		body        string
+		contentType string
+		status      int
+		want        string
+		wantErr     string
+	}{
+		{"default body", openEvent, "", "application/json", http.StatusOK,
+			`{"message": "Garage is \"open\".", "door": "Garage", "event": "open", "duration": "1 hour"}`, ""},
+		{"no event", context.Background(), "", "", http.StatusAccepted,
+			`{"message": "Garage is \"open\".", "door": "", "event": "", "duration": ""}`, ""},
+		{"custom body", openEvent, `door={{.DoorName}}&event={{.Event}}`, "application/x-www-form-urlencoded", http.StatusNoContent,
+			`door=Garage&event=open`, ""},
+		{"rejected", openEvent, "", "application/json", http.StatusUnprocessableEntity,
+			"", "webhook returned HTTP 422: bad payload"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, gotType string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				got, gotType = string(b), r.Header.Get("Content-Type")
+				w.WriteHeader(tt.status)
+				if tt.status >= 300 {
+					w.Write([]byte("bad payload"))
+				}
+			}))
+			defer srv.Close()
+
+			w, err := NewWebhookNotifier(srv.URL, tt.body, tt.contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = w.Send(tt.ctx, `Garage is "open".`)
+
+			var statusErr *httpStatusError
+			if tt.wantErr != "" {
+				if !errors.As(err, &statusErr) || err.Error() != tt.wantErr {
+					t.Errorf("Send() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("body %s, want %s", got, tt.want)
+			}
+			if gotType != tt.contentType {
+				t.Errorf("Content-Type %q, want %q", gotType, tt.contentType)
+			}
+		})
+	}
+}
+
+func TestNewWebhookNotifierBadTemplate(t *testing.T) {
+	if _, err := NewWebhookNotifier("https://example.com", "{{.Door", ""); err == nil {
+		t.Error("NewWebhookNotifier accepted an unterminated template")
+	}
+}